@@ -0,0 +1,377 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.iscode.ca/redact/internal/pkg/unidiff"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+var (
+	errArchiveDepth = errors.New("archive nesting exceeds depth limit")
+	errArchiveSize  = errors.New("decompressed size exceeds limit")
+)
+
+// sniffSize is the number of bytes read to detect the format of a file.
+const sniffSize = 4096
+
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	tarArchive
+	zipArchive
+)
+
+// sniffArchive returns the format of an archive from the first bytes of
+// the file.
+func sniffArchive(b []byte) archiveKind {
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")), bytes.HasPrefix(b, []byte("PK\x05\x06")):
+		return zipArchive
+	case len(b) >= 262 && string(b[257:262]) == "ustar":
+		return tarArchive
+	}
+
+	return notArchive
+}
+
+// archive redacts the members of an archive, including the members of
+// nested and compressed archives, such as tar.gz files. The path of a member is the path of the archive
+// followed by "!" and the name of the member, for example
+// "bundle.tar.gz!logs/app.zip!app.log".
+type archive struct {
+	*state
+
+	ctx context.Context
+	red *redact.Opt
+	log *zerolog.Logger
+
+	// size is the number of bytes which may still be read before the
+	// size limit is reached, shared by all the layers of the file.
+	size *int64
+
+	results []result
+}
+
+func (st *state) newArchive(ctx context.Context, red *redact.Opt, size *int64) *archive {
+	return &archive{
+		state: st,
+		ctx:   ctx,
+		red:   red,
+		log:   zerolog.Ctx(ctx),
+		size:  size,
+	}
+}
+
+// archive redacts the members of the archive. In diff mode, an archive
+// containing secrets is reported as a binary file: a diff of a member
+// could not be applied to the archive. depth is the number of layers of
+// the members, including the archive.
+func (st *state) archive(ctx context.Context, red *redact.Opt, kind archiveKind, name string, r io.Reader, w io.Writer, depth int, size *int64) ([]result, error) {
+	a := st.newArchive(ctx, red, size)
+
+	out := w
+	if st.diff {
+		w = io.Discard
+	}
+
+	if err := a.redact(kind, name, r, w, depth); err != nil {
+		return nil, err
+	}
+
+	if st.diff && len(a.results) > 0 {
+		if err := unidiff.WriteBinary(out, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return a.results, nil
+}
+
+// redact writes a copy of the archive with the members redacted. Member
+// names, modes and timestamps are preserved.
+func (a *archive) redact(kind archiveKind, name string, r io.Reader, w io.Writer, depth int) error {
+	switch kind {
+	case tarArchive:
+		return a.tar(name, r, w, depth)
+	case zipArchive:
+		return a.zip(name, r, w, depth)
+	}
+
+	return nil
+}
+
+func (a *archive) tar(name string, r io.Reader, w io.Writer, depth int) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		path := name + "!" + hdr.Name
+
+		if hdr.Typeflag != tar.TypeReg {
+			if err := tw.WriteHeader(hdr); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			if _, err := io.Copy(tw, tr); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			continue
+		}
+
+		b, err := a.read(path, tr)
+		if err != nil {
+			return err
+		}
+
+		v, err := a.member(path, b, depth)
+		if err != nil {
+			return err
+		}
+
+		hdr.Size = int64(len(v))
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if _, err := tw.Write(v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (a *archive) zip(name string, r io.Reader, w io.Writer, depth int) error {
+	b, err := a.read(name, r)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	if err := zw.SetComment(zr.Comment); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for _, f := range zr.File {
+		path := name + "!" + f.Name
+
+		if f.Flags&0x1 != 0 {
+			a.log.Warn().Str("path", path).Msg("skipped encrypted member")
+		}
+
+		if f.FileInfo().IsDir() || f.Flags&0x1 != 0 {
+			if err := zw.Copy(f); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		content, err := a.read(path, rc)
+		if err := errors.Join(err, rc.Close()); err != nil {
+			return err
+		}
+
+		v, err := a.member(path, content, depth)
+		if err != nil {
+			return err
+		}
+
+		// Unmodified members are copied without recompressing.
+		if bytes.Equal(v, content) {
+			if err := zw.Copy(f); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+
+		fh := f.FileHeader
+		fh.Extra = nil
+
+		mw, err := zw.CreateHeader(&fh)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if _, err := mw.Write(v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// member returns the redacted contents of an archive member. Nested
+// archives are redacted recursively, compressed members are
+// recompressed and binary members are redacted according to the
+// --binary policy.
+func (a *archive) member(path string, b []byte, depth int) ([]byte, error) {
+	if c, ok := sniffCodec(b); ok {
+		if depth >= a.codecDepth() {
+			return nil, fmt.Errorf("%s: %w", path, errArchiveDepth)
+		}
+		return a.decompress(c, path, b, depth+1)
+	}
+
+	if kind := sniffArchive(b); kind != notArchive {
+		if depth >= a.archiveDepth {
+			return nil, fmt.Errorf("%s: %w", path, errArchiveDepth)
+		}
+
+		var w bytes.Buffer
+		if err := a.redact(kind, path, bytes.NewReader(b), &w, depth+1); err != nil {
+			return nil, err
+		}
+
+		return w.Bytes(), nil
+	}
+
+	if a.binary != binaryScan && binary(b[:min(len(b), sniffSize)]) {
+		if a.binary == binarySkip {
+			a.log.Debug().Str("path", path).Msg("skipped binary member")
+			return b, nil
+		}
+
+		v, findings, err := a.redactBinary(path, b, io.Discard)
+		if err != nil {
+			return nil, err
+		}
+
+		a.results = append(a.results, results(path, findings)...)
+		return v, nil
+	}
+
+	a.log.Info().Str("path", path).Msg("matched")
+
+	fn, _ := handler(a.ctx, a.red, path)
+
+	s, findings, err := fn(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	a.results = append(a.results, results(path, findings)...)
+
+	return []byte(s), nil
+}
+
+// decompress returns the redacted contents of a compressed member,
+// recompressed in the same format.
+func (a *archive) decompress(c *codec, path string, b []byte, depth int) ([]byte, error) {
+	zr, err := c.reader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	content, err := a.read(path, zr)
+	if err := errors.Join(err, zr.Close()); err != nil {
+		return nil, err
+	}
+
+	v, err := a.member(path, content, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(v, content) {
+		return b, nil
+	}
+
+	var w bytes.Buffer
+
+	zw, err := c.writer(&w)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if _, err := zw.Write(v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return w.Bytes(), nil
+}
+
+// read reads the remainder of r, counting the bytes against the size
+// limit.
+func (a *archive) read(path string, r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, *a.size+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if int64(len(b)) > *a.size {
+		return nil, fmt.Errorf("%s: %w", path, errArchiveSize)
+	}
+
+	*a.size -= int64(len(b))
+
+	return b, nil
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	v, n := s, int64(1)
+
+	switch {
+	case strings.HasSuffix(s, "K"):
+		n = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		n = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		n = 1 << 30
+	}
+
+	if n > 1 {
+		v = v[:len(v)-1]
+	}
+
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%s: invalid size", s)
+	}
+
+	return size * n, nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// atime returns the access time of the file.
+func atime(fi fs.FileInfo) time.Time {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atimespec.Unix())
+	}
+	return fi.ModTime()
+}
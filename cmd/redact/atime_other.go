@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// atime returns the modification time: the access time is not
+// available on this platform.
+func atime(fi fs.FileInfo) time.Time {
+	return fi.ModTime()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.iscode.ca/redact/internal/pkg/seal"
+)
+
+// journalName is the name of the journal in the backup directory.
+const journalName = "journal.jsonl"
+
+// entry is a line of the journal describing a file redacted in-place.
+type entry struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+
+	// Original and Redacted are the hex encoded SHA-256 digests of the
+	// file before and after redaction.
+	Original string `json:"original"`
+	Redacted string `json:"redacted"`
+
+	// Backup is the name of the copy of the original file in the
+	// backup directory, named by the digest of the original. Encrypted
+	// backups are sealed with the key.
+	Backup    string `json:"backup"`
+	Encrypted bool   `json:"encrypted"`
+
+	Time time.Time `json:"time"`
+}
+
+// backup stores the original of each file redacted in-place and appends
+// an entry to the journal.
+type backup struct {
+	dir string
+	key []byte
+
+	mu      sync.Mutex
+	journal *os.File
+}
+
+// openBackup opens the journal in the backup directory. If the key is
+// set, backups are encrypted.
+func openBackup(dir string, key []byte) (*backup, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, journalName)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &backup{dir: dir, key: key, journal: f}, nil
+}
+
+func (b *backup) Close() error {
+	if err := b.journal.Close(); err != nil {
+		return fmt.Errorf("%s: %w", b.journal.Name(), err)
+	}
+	return nil
+}
+
+// original is the copy of the original content of a file, written to
+// the backup directory while the file is redacted.
+type original struct {
+	b *backup
+	f *os.File
+	w io.WriteCloser
+	h hash.Hash
+
+	// closed is set once the copy is saved or discarded.
+	closed bool
+}
+
+// create starts the copy of the original content of the file.
+func (b *backup) create(path string) (*original, error) {
+	f, err := os.CreateTemp(b.dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.dir, err)
+	}
+
+	o := &original{b: b, f: f, w: nopCloser{f}, h: sha256.New()}
+
+	if b.key != nil {
+		w, err := seal.NewWriter(f, b.key)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("%s: %w", path, err), o.discard())
+		}
+		o.w = w
+	}
+
+	return o, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (o *original) Write(p []byte) (int, error) {
+	o.h.Write(p)
+	return o.w.Write(p)
+}
+
+// digest returns the digest of the content copied.
+func (o *original) digest() string {
+	return hex.EncodeToString(o.h.Sum(nil))
+}
+
+// discard removes the copy.
+func (o *original) discard() error {
+	if o.closed {
+		return nil
+	}
+
+	o.closed = true
+
+	if err := errors.Join(o.f.Close(), os.Remove(o.f.Name())); err != nil {
+		return fmt.Errorf("%s: %w", o.f.Name(), err)
+	}
+
+	return nil
+}
+
+// save stores the copy of the original content of the file and records
+// the digest of the redacted content.
+func (o *original) save(path string, redacted string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	e := entry{
+		Path:      abs,
+		Original:  o.digest(),
+		Redacted:  redacted,
+		Backup:    o.digest(),
+		Encrypted: o.b.key != nil,
+		Time:      time.Now().UTC(),
+	}
+
+	if e.Encrypted {
+		e.Backup += ".sealed"
+	}
+
+	if err := o.write(e.Backup); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	b := o.b
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("%s: %w", b.journal.Name(), err)
+	}
+
+	if err := b.journal.Sync(); err != nil {
+		return fmt.Errorf("%s: %w", b.journal.Name(), err)
+	}
+
+	return nil
+}
+
+// write atomically renames the copy to the backup file. Files with the
+// same content share a backup.
+func (o *original) write(name string) error {
+	path := filepath.Join(o.b.dir, name)
+
+	o.closed = true
+
+	err := o.w.Close()
+	if err == nil {
+		err = o.f.Sync()
+	}
+	err = errors.Join(err, o.f.Close())
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, errors.Join(err, os.Remove(o.f.Name())))
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Remove(o.f.Name()); err != nil {
+			return fmt.Errorf("%s: %w", o.f.Name(), err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(o.f.Name(), path); err != nil {
+		return fmt.Errorf("%s: %w", path, errors.Join(err, os.Remove(o.f.Name())))
+	}
+
+	return syncDir(o.b.dir)
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the file.
+func fileDigest(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"go.iscode.ca/redact/internal/pkg/unidiff"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// Policies for binary files set by --binary.
+const (
+	binarySkip    = "skip"
+	binaryScan    = "scan"
+	binaryStrings = "redact-strings"
+)
+
+// minStringLength is the minimum length of a printable run redacted by
+// the redact-strings policy.
+const minStringLength = 4
+
+// binary reports whether the first bytes of a file are binary: the bytes
+// contain a NUL or more than 30% of the bytes are invalid UTF-8.
+func binary(b []byte) bool {
+	if bytes.IndexByte(b, 0) >= 0 {
+		return true
+	}
+
+	invalid := 0
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && n == 1 {
+			// A rune may be truncated at the end of the sample.
+			if !utf8.FullRune(b[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += n
+	}
+
+	return invalid*10 > len(b)*3
+}
+
+// printable reports whether the byte is part of a printable string.
+func printable(c byte) bool {
+	return c >= 0x20 && c < 0x7f || c == '\t'
+}
+
+// redactStrings redacts the printable strings of a binary file. Secrets
+// are overwritten by replacements of the same length: the length of the
+// file and all other bytes are unmodified.
+func (st *state) redactStrings(b []byte) ([]byte, []redact.Finding, error) {
+	out := bytes.Clone(b)
+	findings := make([]redact.Finding, 0)
+
+	for i := 0; i < len(b); {
+		if !printable(b[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(b) && printable(b[i]) {
+			i++
+		}
+
+		if i-start < minStringLength {
+			continue
+		}
+
+		s, fs, err := st.strings.RedactWithFindings(string(b[start:i]))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		copy(out[start:i], s)
+
+		for _, f := range fs {
+			f.Start += start
+			f.End += start
+			f.StartLine, f.StartColumn = position(b, f.Start)
+			f.EndLine, f.EndColumn = position(b, f.End-1)
+			// Printable strings are ASCII: a byte is a code unit.
+			f.StartColumnUTF16, f.EndColumnUTF16 = f.StartColumn, f.EndColumn+1
+			findings = append(findings, f)
+		}
+	}
+
+	return out, findings, nil
+}
+
+// position returns the 1-based line and column of the byte at offset off.
+func position(b []byte, off int) (int, int) {
+	line := 1 + bytes.Count(b[:off], []byte("\n"))
+	return line, off - bytes.LastIndexByte(b[:off], '\n')
+}
+
+// binaryFile redacts a binary file according to the --binary policy.
+// Skipped files are copied unmodified. In diff mode, a modified binary
+// file is reported without the contents.
+func (st *state) binaryFile(name string, r io.Reader, w io.Writer) ([]result, error) {
+	if st.binary == binarySkip {
+		if st.diff {
+			return nil, nil
+		}
+
+		if _, err := io.Copy(w, r); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	v, findings, err := st.redactBinary(name, b, w)
+	if err != nil {
+		return nil, err
+	}
+
+	if !st.diff {
+		if _, err := w.Write(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return results(name, findings), nil
+}
+
+// redactBinary returns the binary file read into memory with the strings
+// redacted. In diff mode, the file is unmodified and a line noting the
+// difference is written to w.
+func (st *state) redactBinary(name string, b []byte, w io.Writer) ([]byte, []redact.Finding, error) {
+	v, findings, err := st.redactStrings(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if st.diff {
+		if len(findings) > 0 {
+			if err := unidiff.WriteBinary(w, name); err != nil {
+				return nil, nil, err
+			}
+		}
+		return b, findings, nil
+	}
+
+	return v, findings, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	dsbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"go.iscode.ca/redact/internal/pkg/unidiff"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+type compression int
+
+const (
+	gzipCompression compression = iota + 1
+	zstdCompression
+	bzip2Compression
+)
+
+// codec is the compression format of a file. Files are recompressed
+// with the settings detected in the header of the original file.
+type codec struct {
+	format compression
+
+	// level is the compression level of gzip and bzip2 files: the
+	// level of zstd files is not recorded in the file.
+	level int
+
+	// checksum is set if the zstd frame has a content checksum.
+	checksum bool
+
+	// header is the gzip header of the original file.
+	header gzip.Header
+}
+
+// sniffCodec returns the compression format of a file from its first
+// bytes.
+func sniffCodec(b []byte) (*codec, bool) {
+	switch {
+	case len(b) >= 10 && b[0] == 0x1f && b[1] == 0x8b:
+		// The extra flags record the best and fastest levels.
+		level := gzip.DefaultCompression
+		switch b[8] {
+		case 2:
+			level = gzip.BestCompression
+		case 4:
+			level = gzip.BestSpeed
+		}
+
+		return &codec{format: gzipCompression, level: level}, true
+	case len(b) >= 5 && bytes.HasPrefix(b, []byte("\x28\xb5\x2f\xfd")):
+		return &codec{format: zstdCompression, checksum: b[4]&0x4 != 0}, true
+	case len(b) >= 4 && bytes.HasPrefix(b, []byte("BZh")) && b[3] >= '1' && b[3] <= '9':
+		// The block size is the compression level.
+		return &codec{format: bzip2Compression, level: int(b[3] - '0')}, true
+	}
+
+	return nil, false
+}
+
+// reader returns a reader decompressing r.
+func (c *codec) reader(r io.Reader) (io.ReadCloser, error) {
+	switch c.format {
+	case gzipCompression:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		c.header = gr.Header
+
+		return gr, nil
+	case zstdCompression:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	case bzip2Compression:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %d", c.format)
+}
+
+// writer returns a writer compressing to w in the format of the original
+// file.
+func (c *codec) writer(w io.Writer) (io.WriteCloser, error) {
+	switch c.format {
+	case gzipCompression:
+		gw, err := gzip.NewWriterLevel(w, c.level)
+		if err != nil {
+			return nil, err
+		}
+
+		gw.Header = c.header
+
+		return gw, nil
+	case zstdCompression:
+		return zstd.NewWriter(w, zstd.WithEncoderCRC(c.checksum))
+	case bzip2Compression:
+		return dsbzip2.NewWriter(w, &dsbzip2.WriterConfig{Level: c.level})
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %d", c.format)
+}
+
+// limitReader reads from r, failing if more than n bytes are read. n is
+// shared by the layers of a file.
+type limitReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+
+	*l.n -= int64(n)
+	if *l.n < 0 {
+		return n, errArchiveSize
+	}
+
+	return n, err
+}
+
+// codecDepth returns the maximum number of layers of compressed files and
+// archives around a compressed file. A single compressed file is
+// decompressed even if archives are redacted as files.
+func (st *state) codecDepth() int {
+	return max(st.archiveDepth, 1)
+}
+
+// decompress redacts the decompressed contents of the file. The output
+// is compressed in the same format, except in check and diff modes. A
+// diff of the decompressed contents could not be applied to the file: in
+// diff mode, a compressed file containing secrets is reported as a binary
+// file.
+// depth is the number of layers of the decompressed contents and size the
+// number of bytes which may still be read from all layers.
+func (st *state) decompress(ctx context.Context, red *redact.Opt, c *codec, name string, r io.Reader, w io.Writer, depth int, size *int64) (res []result, err error) {
+	zr, err := c.reader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	defer func() {
+		err = errors.Join(err, zr.Close())
+	}()
+
+	if !st.check && !st.diff {
+		zw, err := c.writer(w)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		defer func() {
+			if e := zw.Close(); e != nil {
+				err = errors.Join(err, fmt.Errorf("%s: %w", name, e))
+			}
+		}()
+
+		w = zw
+	}
+
+	lr := &limitReader{r: zr, n: size}
+
+	if st.diff {
+		res, err := st.redact(ctx, red, name, bufio.NewReaderSize(lr, sniffSize), io.Discard, depth, size)
+		if err != nil || len(res) == 0 {
+			return res, err
+		}
+
+		if err := unidiff.WriteBinary(w, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		return res, nil
+	}
+
+	return st.redact(ctx, red, name, bufio.NewReaderSize(lr, sniffSize), w, depth, size)
+}
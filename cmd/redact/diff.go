@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+
+	"go.iscode.ca/redact/internal/pkg/unidiff"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// unidiff writes the proposed redactions as a unified diff. Each hunk is
+// annotated with the IDs of the rules matching the secrets.
+func (st *state) unidiff(fn redactFunc, name string, r io.Reader, w io.Writer) ([]redact.Finding, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, findings, err := fn(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	regions := make([]unidiff.Region, 0, len(findings))
+	for _, f := range findings {
+		regions = append(regions, unidiff.Region{
+			Start:  f.StartLine,
+			End:    f.EndLine,
+			Labels: []string{f.RuleID},
+		})
+	}
+
+	return findings, unidiff.Write(w, name, string(b), s, regions)
+}
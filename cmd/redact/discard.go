@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// discard reads the input without writing any output.
+type discard struct {
+	r *os.File
+}
+
+func (rw *discard) Open() error {
+	return nil
+}
+
+func (rw *discard) Close() error {
+	return nil
+}
+
+func (rw *discard) In() io.Reader {
+	return rw.r
+}
+
+func (rw *discard) Out() io.Writer {
+	return io.Discard
+}
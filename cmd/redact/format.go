@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// redactFunc redacts a document read into memory.
+type redactFunc func(s string) (string, []redact.Finding, error)
+
+// handler returns the function redacting the file as a structured
+// document selected by the file name. Files which are not structured
+// documents are redacted as text.
+func handler(ctx context.Context, red *redact.Opt, name string) (redactFunc, bool) {
+	var fn redactFunc
+
+	base := strings.ToLower(filepath.Base(name))
+
+	// Compressed files are selected by the name of the decompressed file.
+	for _, ext := range []string{".gz", ".zst", ".bz2"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	switch ext := filepath.Ext(base); {
+	case ext == ".json":
+		fn = red.RedactJSONWithFindings
+	case ext == ".yaml", ext == ".yml":
+		fn = red.RedactYAMLWithFindings
+	case ext == ".env", base == ".env", strings.HasPrefix(base, ".env."):
+		fn = red.RedactDotenvWithFindings
+	case ext == ".ini":
+		fn = red.RedactINIWithFindings
+	case ext == ".properties":
+		fn = red.RedactPropertiesWithFindings
+	default:
+		return red.RedactWithFindings, false
+	}
+
+	return func(s string) (string, []redact.Finding, error) {
+		v, findings, err := fn(s)
+		if errors.Is(err, redact.ErrInvalidJSON) || errors.Is(err, redact.ErrInvalidYAML) {
+			zerolog.Ctx(ctx).Warn().Str("path", name).Msg("redacting as text: " + err.Error())
+			return red.RedactWithFindings(s)
+		}
+		return v, findings, err
+	}, true
+}
+
+// document redacts the input as a whole.
+func document(fn redactFunc, r io.Reader, w io.Writer) ([]redact.Finding, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, findings, err := fn(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, s); err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
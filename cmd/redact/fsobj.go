@@ -1,8 +1,11 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,19 +16,57 @@ type fsobj struct {
 
 	r *os.File
 	w *os.File
+
+	// fi is the original file, whose metadata is copied to the redacted
+	// file.
+	fi os.FileInfo
+
+	// stdout is the output when the file is not redacted in-place.
+	stdout io.Writer
+
+	// When backups are enabled, the original content is copied to the
+	// backup directory as it is read from in, and digest is the digest
+	// of the redacted file.
+	original *original
+	in       io.Reader
+	digest   hash.Hash
+
+	// modified is set if the content of the file was changed. An
+	// unmodified file, such as a skipped binary file, is left untouched.
+	modified bool
 }
 
 func (rw *fsobj) Open() error {
 	if !rw.inplace {
-		rw.w = os.Stdout
 		return nil
 	}
 
-	w, err := os.CreateTemp("", filepath.Base(rw.r.Name()))
+	fi, err := rw.r.Stat()
 	if err != nil {
 		return fmt.Errorf("%s: %w", rw.r.Name(), err)
 	}
 
+	rw.fi = fi
+
+	if rw.backup != nil {
+		o, err := rw.backup.create(rw.r.Name())
+		if err != nil {
+			return err
+		}
+
+		rw.original = o
+		rw.in = io.TeeReader(rw.r, o)
+		rw.digest = sha256.New()
+	}
+
+	// The temporary file is created in the directory of the file: a
+	// rename across file systems fails and the content must not be
+	// left in a shared directory.
+	w, err := os.CreateTemp(filepath.Dir(rw.r.Name()), "."+filepath.Base(rw.r.Name())+".*")
+	if err != nil {
+		return errors.Join(fmt.Errorf("%s: %w", rw.r.Name(), err), rw.discardOriginal())
+	}
+
 	rw.w = w
 
 	return nil
@@ -36,21 +77,74 @@ func (rw *fsobj) Close() error {
 		return nil
 	}
 
-	err := rw.w.Sync()
-	if err != nil {
-		return fmt.Errorf("%s: %w", rw.w.Name(), err)
+	// The redaction may stop before the end of the file, such as
+	// after the end of a tar archive: the remainder is copied to the
+	// backup.
+	if rw.original != nil && rw.modified {
+		if _, err := io.Copy(io.Discard, rw.in); err != nil {
+			err = fmt.Errorf("%s: %w", rw.r.Name(), err)
+			return errors.Join(err, rw.w.Close(), os.Remove(rw.w.Name()), rw.discardOriginal())
+		}
 	}
 
-	defer func() {
-		err = errors.Join(err, os.Remove(rw.w.Name()))
-	}()
+	if !rw.changed() {
+		if err := errors.Join(rw.w.Close(), os.Remove(rw.w.Name())); err != nil {
+			return errors.Join(fmt.Errorf("%s: %w", rw.w.Name(), err), rw.discardOriginal())
+		}
+		return rw.discardOriginal()
+	}
 
-	err = os.Rename(rw.w.Name(), rw.r.Name())
-	if err != nil {
-		return fmt.Errorf("%s: %w", rw.w.Name(), err)
+	if err := rw.commit(); err != nil {
+		return errors.Join(err, rw.w.Close(), os.Remove(rw.w.Name()), rw.discardOriginal())
 	}
 
 	if err := rw.w.Close(); err != nil {
+		return fmt.Errorf("%s: %w", rw.r.Name(), err)
+	}
+
+	// The rename is durable once the directory is synced.
+	if err := syncDir(filepath.Dir(rw.r.Name())); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Dir(rw.r.Name()), err)
+	}
+
+	return nil
+}
+
+// changed reports whether the content of the file was modified. The
+// content is compared to the original when backups are enabled.
+func (rw *fsobj) changed() bool {
+	if !rw.modified {
+		return false
+	}
+	return rw.digest == nil || hex.EncodeToString(rw.digest.Sum(nil)) != rw.original.digest()
+}
+
+// discardOriginal removes the copy of the original content.
+func (rw *fsobj) discardOriginal() error {
+	if rw.original == nil {
+		return nil
+	}
+	return rw.original.discard()
+}
+
+// commit replaces the file with the redacted file. The original is saved
+// to the backup directory first.
+func (rw *fsobj) commit() error {
+	if err := copyMeta(rw.w, rw.r.Name(), rw.fi); err != nil {
+		return err
+	}
+
+	if rw.original != nil {
+		if err := rw.original.save(rw.r.Name(), hex.EncodeToString(rw.digest.Sum(nil))); err != nil {
+			return err
+		}
+	}
+
+	if err := rw.w.Sync(); err != nil {
+		return fmt.Errorf("%s: %w", rw.w.Name(), err)
+	}
+
+	if err := os.Rename(rw.w.Name(), rw.r.Name()); err != nil {
 		return fmt.Errorf("%s: %w", rw.w.Name(), err)
 	}
 
@@ -58,9 +152,18 @@ func (rw *fsobj) Close() error {
 }
 
 func (rw *fsobj) In() io.Reader {
+	if rw.in != nil {
+		return rw.in
+	}
 	return rw.r
 }
 
 func (rw *fsobj) Out() io.Writer {
+	if !rw.inplace {
+		return rw.stdout
+	}
+	if rw.digest != nil {
+		return io.MultiWriter(rw.w, rw.digest)
+	}
 	return rw.w
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redactInPlace rewrites the file in-place with the content.
+func redactInPlace(t *testing.T, path, content string) {
+	t.Helper()
+	redactFile(t, &state{inplace: true}, path, content)
+}
+
+// redactFile rewrites the file with the content.
+func redactFile(t *testing.T, st *state, path, content string) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+
+	rw := &fsobj{state: st, r: f, modified: true}
+
+	if err := rw.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	if _, err := io.Copy(rw.Out(), strings.NewReader(content)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestFsobj_preserveMode(t *testing.T) {
+	for _, mode := range []fs.FileMode{0o600, 0o640, 0o755, 0o444} {
+		path := filepath.Join(t.TempDir(), "file")
+
+		if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("chmod: %v", err)
+		}
+
+		redactInPlace(t, path, "**REDACTED**\n")
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+
+		if fi.Mode().Perm() != mode {
+			t.Errorf("mode: %v, expected %v", fi.Mode().Perm(), mode)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+
+		if string(b) != "**REDACTED**\n" {
+			t.Errorf("content: %q", b)
+		}
+	}
+}
+
+func TestFsobj_preserveTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+
+	if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	if err := os.Chtimes(path, at, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	redactInPlace(t, path, "**REDACTED**\n")
+
+	v, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	if !v.ModTime().Equal(mtime) {
+		t.Errorf("mtime: %v, expected %v", v.ModTime(), mtime)
+	}
+
+	// The access time is only available on some platforms.
+	if v := atime(v); !v.Equal(mtime) && !v.Equal(at) {
+		t.Errorf("atime: %v, expected %v", v, at)
+	}
+}
+
+func TestFsobj_unmodified(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	if err := os.WriteFile(path, []byte("\x00binary\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+
+	rw := &fsobj{state: &state{inplace: true}, r: f}
+
+	if err := rw.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	if _, err := io.Copy(rw.Out(), rw.In()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	if !os.SameFile(before, after) {
+		t.Errorf("file replaced")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("temporary file not removed: %v", entries)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.iscode.ca/redact/internal/pkg/ignore"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// ignoreFile excludes files from directory walks using gitignore
+// patterns. .gitignore files are read if enabled by --gitignore.
+const ignoreFile = ".redactignore"
+
+// fingerprintFile lists the gitleaks fingerprints of secrets which are
+// not redacted. Paths in the fingerprints are relative to the directory
+// of the file.
+const fingerprintFile = ".gitleaksignore"
+
+// fingerprintOptions returns the options setting the fingerprints read
+// from the --ignore-file. By default, the fingerprint files of the
+// current directory and of the directories being walked are read, if
+// they exist.
+func fingerprintOptions(path string, roots []string) ([]redact.Option, error) {
+	if path != "" {
+		opt, err := readFingerprints(path)
+		if err != nil {
+			return nil, err
+		}
+		return []redact.Option{opt}, nil
+	}
+
+	dirs := []string{"."}
+	for _, v := range roots {
+		if fi, err := os.Stat(v); err == nil && fi.IsDir() {
+			dirs = append(dirs, v)
+		}
+	}
+
+	opts := make([]redact.Option, 0, len(dirs))
+
+	for _, dir := range dirs {
+		opt, err := readFingerprints(filepath.Join(dir, fingerprintFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+
+	return opts, nil
+}
+
+// readFingerprints returns the option setting the fingerprints of the
+// file.
+func readFingerprints(name string) (redact.Option, error) {
+	dir, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	v, err := redact.ReadIgnoreFingerprints(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return redact.WithIgnoreFingerprints(dir, v...), nil
+}
+
+// absPath returns the absolute path of the file compared to the paths
+// of fingerprints.
+func absPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
+
+// readIgnore adds the patterns of the ignore files in the directory. rel
+// is the path of the directory relative to the root of the walk.
+func (st *state) readIgnore(m *ignore.Matcher, dir, rel string) error {
+	names := []string{ignoreFile}
+	if st.gitignore {
+		names = []string{".gitignore", ignoreFile}
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		err = m.Add(rel, f)
+		if err := errors.Join(err, f.Close()); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// included reports whether the file matches the --include patterns.
+func (st *state) included(rel string) bool {
+	return st.include == nil || st.include.Match(rel, false)
+}
+
+// relPath returns the slash separated path relative to the root of the
+// walk. The root is relative to itself if it is a directory, otherwise
+// its name is used.
+func relPath(root, path string, de fs.DirEntry) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	if rel == "." && !de.IsDir() {
+		rel = filepath.Base(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// task is a file processed by a worker. When files are processed
+// concurrently, the output and logs of a task are buffered and written
+// in the order the files were visited.
+type task struct {
+	path string
+	r    *os.File
+
+	// root is the path on the command line containing the file.
+	// skipped is set if the file is excluded from the directory walk.
+	root    string
+	skipped bool
+
+	log    zerolog.Logger
+	stdout io.Writer
+	stderr io.Writer
+
+	results []result
+	err     error
+
+	done chan struct{}
+}
+
+// scheduler distributes tasks to a pool of workers.
+type scheduler struct {
+	*state
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	red    *redact.Opt
+
+	tasks chan *task
+	queue chan *task
+
+	wg  sync.WaitGroup
+	err chan error
+}
+
+func (st *state) newScheduler(ctx context.Context, red *redact.Opt) *scheduler {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &scheduler{
+		state:  st,
+		ctx:    ctx,
+		cancel: cancel,
+		red:    red,
+	}
+
+	if st.jobs < 2 {
+		return s
+	}
+
+	s.tasks = make(chan *task)
+	s.queue = make(chan *task, st.jobs*4)
+	s.err = make(chan error, 1)
+
+	for i := 0; i < st.jobs; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for t := range s.tasks {
+				s.process(s.ctx, s.red, t)
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		for t := range s.queue {
+			<-t.done
+			if err != nil {
+				continue
+			}
+			if err = s.emit(t); err != nil {
+				s.cancel()
+			}
+		}
+		s.err <- err
+	}()
+
+	return s
+}
+
+// newTask returns a task for the file. The output of the task is
+// buffered when files are processed concurrently.
+func (s *scheduler) newTask(path string) *task {
+	t := &task{
+		path:   path,
+		log:    log.Logger,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		done:   make(chan struct{}),
+	}
+
+	if s.tasks != nil {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		t.stdout = stdout
+		t.stderr = stderr
+		t.log = log.Output(stderr)
+	}
+
+	return t
+}
+
+// submit queues the task. A task without a file only writes its logs.
+func (s *scheduler) submit(t *task) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.tasks == nil {
+		if t.r != nil {
+			s.process(s.ctx, s.red, t)
+		}
+		return s.emit(t)
+	}
+
+	if t.r == nil {
+		close(t.done)
+		s.queue <- t
+		return nil
+	}
+
+	s.queue <- t
+	s.tasks <- t
+
+	return nil
+}
+
+// wait waits for all tasks to complete, returning the first error in
+// the order the tasks were submitted.
+func (s *scheduler) wait() error {
+	defer s.cancel()
+
+	if s.tasks == nil {
+		return nil
+	}
+
+	close(s.tasks)
+	s.wg.Wait()
+	close(s.queue)
+
+	return <-s.err
+}
+
+// emit writes the buffered output of the task and records the findings.
+func (s *scheduler) emit(t *task) error {
+	if b, ok := t.stderr.(*bytes.Buffer); ok {
+		if _, err := b.WriteTo(os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	if b, ok := t.stdout.(*bytes.Buffer); ok {
+		if _, err := b.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if s.stats != nil {
+		s.stats.add(t)
+	}
+
+	if t.err != nil {
+		return t.err
+	}
+
+	s.record(t.results)
+
+	if s.check {
+		for _, f := range t.results {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", f.Path, f.StartLine, f.StartColumn, f.RuleID)
+		}
+	}
+
+	return nil
+}
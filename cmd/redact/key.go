@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errNoKey = errors.New("no key: use --key-file or set REDACT_KEY")
+
+// readKey returns the key used by keyed redaction methods. The key is
+// read from a file or from the REDACT_KEY environment variable.
+func readKey(s string) ([]byte, error) {
+	if s == "" {
+		if v := os.Getenv("REDACT_KEY"); v != "" {
+			return []byte(v), nil
+		}
+		return nil, errNoKey
+	}
+
+	b, err := os.ReadFile(s)
+	if err != nil {
+		return nil, err
+	}
+
+	b = bytes.TrimRight(b, "\r\n")
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%s: %w", s, errNoKey)
+	}
+
+	return b, nil
+}
+
+// fingerprintKey returns the key of the fingerprints of secrets in
+// reports: the key if set, otherwise a random key. Fingerprints only
+// match across runs if a key is set.
+func (st *state) fingerprintKey() ([]byte, error) {
+	if st.key != nil {
+		return st.key, nil
+	}
+
+	key, err := readKey(st.keyFile)
+	switch {
+	case err == nil:
+		return key, nil
+	case st.keyFile != "":
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
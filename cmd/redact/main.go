@@ -5,6 +5,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -19,17 +21,65 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.iscode.ca/redact/internal/pkg/fdpair"
+	"go.iscode.ca/redact/internal/pkg/ignore"
 	"go.iscode.ca/redact/pkg/redact"
 	"go.iscode.ca/redact/pkg/redact/overwrite"
+	"go.iscode.ca/redact/pkg/redact/vault"
 )
 
 const (
 	version = "0.4.1"
 )
 
+// Exit status in check mode.
+const (
+	statusFound = 1
+	statusError = 2
+)
+
 type state struct {
 	inplace bool
+	check   bool
+	diff    bool
+	jobs    int
+	found   bool
 	skip    []string
+	report  string
+
+	// reportFormat is the format of the report: json or sarif.
+	reportFormat string
+
+	// stats summarizes the run if --stats is set.
+	stats       *stats
+	statsFormat statsFormat
+
+	// backup saves the originals of files redacted in-place.
+	backup *backup
+
+	// gitignore enables reading .gitignore files in directory walks.
+	// include restricts the files redacted in directory walks.
+	gitignore bool
+	include   *ignore.Matcher
+
+	results []result
+
+	archiveDepth int
+	archiveSize  int64
+
+	// binary is the policy for binary files. strings redacts the
+	// strings of binary files, preserving their length.
+	binary  string
+	strings *redact.Opt
+
+	substitute string
+	keyFile    string
+	key        []byte
+	vaultFile  string
+	vault      *vault.Vault
+
+	// exclude are the absolute paths of the backup directory and of the
+	// vault, which are never redacted.
+	exclude []string
 }
 
 func usage() {
@@ -46,6 +96,17 @@ Examples:
   # from stdin
   cat file | redact -
 
+  # check for secrets without modifying files
+  redact --check .
+
+  # replace secrets with tokens and restore them
+  redact --remove=token --vault secrets.vault file > redacted
+  redact unredact --vault secrets.vault redacted
+
+  # keep the originals of redacted files and restore them
+  redact -i --backup-dir backup .
+  redact undo backup/journal.jsonl
+
 Options:
 
 `, path.Base(os.Args[0]), version, os.Args[0])
@@ -64,30 +125,100 @@ func getenvbool(s string) bool {
 	return ok
 }
 
+func getenvint(s string, def int) int {
+	if v, ok := os.LookupEnv(s); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// isFlagSet reports whether the flag was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "unredact" {
+		unredact(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "undo" {
+		undo(os.Args[2:])
+		return
+	}
+
 	envSkip := getenv("REDACT_SKIP", ".git .gitleaks.toml")
 	envRemove := getenv("REDACT_REMOVE", "redact")
 	envSubstitute := getenv("REDACT_SUBSTITUTE", redact.ReplacementText)
 	envRules := getenv("REDACT_RULES", "")
+	envKeyPaths := getenv("REDACT_KEY_PATHS", "")
+	envReport := getenv("REDACT_REPORT", "")
+	envReportFormat := getenv("REDACT_REPORT_FORMAT", reportJSON)
+	envKeyFile := getenv("REDACT_KEY_FILE", "")
+	envVault := getenv("REDACT_VAULT", "")
 	envLogLevel := getenv("REDACT_LOG_LEVEL", zerolog.LevelErrorValue)
+	envJobs := getenvint("REDACT_JOBS", 1)
+	envArchiveDepth := getenvint("REDACT_ARCHIVE_DEPTH", 3)
+	envArchiveSize := getenv("REDACT_ARCHIVE_SIZE", "1G")
+	envBinary := getenv("REDACT_BINARY", binarySkip)
+	envIgnoreFile := getenv("REDACT_IGNORE_FILE", "")
 
 	envInPlace := getenvbool("REDACT_INPLACE")
-
-	remove := flag.String("remove", envRemove, "Redaction method: redact, mask")
-	substitute := flag.String("substitute", envSubstitute, "Text used to overwrite secrets")
-	flag.StringVar(substitute, "s", envSubstitute, "Text used to overwrite secrets")
+	envCheck := getenvbool("REDACT_CHECK")
+	envGitignore := getenvbool("REDACT_GITIGNORE")
+	envStats := getenv("REDACT_STATS", "")
+	envBackupDir := getenv("REDACT_BACKUP_DIR", "")
+	envBackupEncrypt := getenvbool("REDACT_BACKUP_ENCRYPT")
+	envInclude := getenv("REDACT_INCLUDE", "")
+	envDiff := getenvbool("REDACT_DIFF")
+
+	remove := flag.String("remove", envRemove, "Redaction method: redact, mask, fake, hmac, token")
+	substitute := flag.String("substitute", envSubstitute, "Text or template used to overwrite secrets")
+	flag.StringVar(substitute, "s", envSubstitute, "Text or template used to overwrite secrets")
+	keyFile := flag.String("key-file", envKeyFile, "Path to file containing the key for keyed redaction methods")
+	vaultFile := flag.String("vault", envVault, "Path to the encrypted file storing secrets replaced by tokens")
 	rules := flag.String("rules", envRules, "Path to file containing gitleaks rules")
+	keyPaths := flag.String("key-paths", envKeyPaths, "Redact values in structured documents matching key paths (for example, $..password)")
+	report := flag.String("report", envReport, "Write findings to file")
+	reportFormat := flag.String("report-format", envReportFormat, "Format of the report: json or sarif")
 	logLevel := flag.String("log-level", envLogLevel, "Set log level")
+	jobs := flag.Int("jobs", envJobs, "Number of files processed concurrently")
+	flag.IntVar(jobs, "j", envJobs, "Number of files processed concurrently")
+	archiveDepth := flag.Int("archive-depth", envArchiveDepth, "Maximum nesting of archives and compressed files (0 to redact archives as files)")
+	archiveSize := flag.String("archive-size", envArchiveSize, "Maximum bytes read from the archives and compressed files in a file, including decompressed contents (K, M or G suffix)")
+	binaryPolicy := flag.String("binary", envBinary, "Binary files: skip, scan (redact as text) or redact-strings (redact printable strings, preserving the file length)")
 	skip := flag.String("skip", envSkip, "Skip glob matches in directories")
 	flag.StringVar(skip, "S", envSkip, "Skip glob matches in directories")
+	gitignore := flag.Bool("gitignore", envGitignore, "Exclude files matching .gitignore patterns in directories")
+	ignorePath := flag.String("ignore-file", envIgnoreFile, "Path to file containing gitleaks fingerprints of secrets which are not redacted (default .gitleaksignore)")
+	include := flag.String("include", envInclude, "Redact only files matching gitignore patterns in directories (for example, '*.log *.conf')")
+
+	backupDir := flag.String("backup-dir", envBackupDir, "Save the originals of files redacted in-place to the directory and record them in a journal")
+	backupEncrypt := flag.Bool("backup-encrypt", envBackupEncrypt, "Encrypt backups using the key from --key-file or REDACT_KEY")
+
+	var format statsFormat
+	envStatsErr := format.Set(envStats)
+	flag.Var(&format, "stats", "Write a summary of the run to stderr: --stats or --stats=json")
 
 	inplace := flag.Bool("inplace", envInPlace, "Redact the file in-place")
 	flag.BoolVar(inplace, "i", envInPlace, "Redact the file in-place")
 
+	diff := flag.Bool("diff", envDiff, "Write the redactions as a unified diff to stdout without modifying files")
+	check := flag.Bool("check", envCheck, "Report secrets without redacting: exit status 1 if secrets are found, 2 on error")
+
 	flag.Usage = func() { usage() }
 	flag.Parse()
 
-	if flag.NArg() == 0 {
+	if flag.NArg() == 0 || *check && *diff {
 		flag.Usage()
 		os.Exit(2)
 	}
@@ -99,155 +230,506 @@ func main() {
 	}
 	zerolog.SetGlobalLevel(l)
 
-	b, err := readRules(*rules)
+	switch *reportFormat {
+	case reportJSON, reportSARIF:
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch *binaryPolicy {
+	case binarySkip, binaryScan, binaryStrings:
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// An invalid REDACT_STATS is rejected unless --stats overrides it.
+	if envStatsErr != nil && !isFlagSet("stats") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *jobs < 1 || *archiveDepth < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	maxArchiveSize, err := parseSize(*archiveSize)
 	if err != nil {
-		log.Fatal().Msg(err.Error())
+		flag.Usage()
+		os.Exit(2)
 	}
 
 	st := &state{
 		inplace: *inplace,
+		check:   *check,
+		diff:    *diff,
+		jobs:    *jobs,
 		skip:    strings.Fields(*skip),
-	}
+		report:  *report,
+
+		reportFormat: *reportFormat,
+		results:      make([]result, 0),
+
+		gitignore: *gitignore,
+
+		statsFormat: format,
 
-	var replace overwrite.Replacer = &overwrite.Redact{Text: *substitute}
+		archiveDepth: *archiveDepth,
+		archiveSize:  maxArchiveSize,
+		binary:       *binaryPolicy,
 
-	before, after, ok := strings.Cut(*remove, ":")
+		substitute: *substitute,
+		keyFile:    *keyFile,
+		vaultFile:  *vaultFile,
+	}
+
+	if st.statsFormat != "" {
+		st.stats = newStats()
+	}
 
-	switch before {
-	case "redact":
-	case "mask":
-		unmasked := 0
-		if ok {
-			n, err := strconv.Atoi(after)
+	if *backupDir != "" {
+		var key []byte
+		if *backupEncrypt {
+			key, err = readKey(st.keyFile)
 			if err != nil {
-				log.Fatal().Msg(err.Error())
+				st.fatal("", err)
 			}
-			if n < 0 || n > 100 {
-				log.Fatal().Str("arg", after).Msg("unmasked value must be a percentage in range 0-100")
-			}
-			unmasked = n
 		}
 
-		var char byte = '*'
-		if len(*substitute) > 0 {
-			char = (*substitute)[0]
+		st.backup, err = openBackup(*backupDir, key)
+		if err != nil {
+			st.fatal("", err)
 		}
-		replace = &overwrite.Mask{Char: char, Unmasked: unmasked}
-	default:
-		log.Fatal().Str("arg", before).Msg("invalid option for --remove")
+
+		st.exclude = append(st.exclude, absPath(*backupDir))
 	}
 
-	red := redact.New(
+	if st.vaultFile != "" {
+		st.exclude = append(st.exclude, absPath(st.vaultFile))
+	}
+
+	if patterns := strings.Fields(*include); len(patterns) > 0 {
+		st.include = &ignore.Matcher{}
+		st.include.AddPatterns("", patterns...)
+	}
+
+	b, err := readRules(*rules)
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	ignoreOpts, err := fingerprintOptions(*ignorePath, flag.Args())
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	replace, err := st.method(*remove)
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	fingerprintKey, err := st.fingerprintKey()
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	opts := []redact.Option{
+		redact.WithFingerprintKey(fingerprintKey),
 		redact.WithOverwrite(replace),
+		redact.WithParser(st.method),
 		redact.WithRules(string(b)),
-	)
+		redact.WithKeyPaths(strings.Fields(*keyPaths)...),
+	}
+	opts = append(opts, ignoreOpts...)
+
+	red := redact.New(opts...)
+	if err := red.Err(); err != nil {
+		st.fatal("", err)
+	}
+
+	if st.binary == binaryStrings {
+		st.strings = redact.New(append(opts, redact.WithFixedLength())...)
+	}
+
+	sched := st.newScheduler(context.Background(), red)
 
 	for _, v := range flag.Args() {
+		var err error
+
 		switch v {
 		case "-":
-			rw := &stdio{
-				r:     os.Stdin,
-				state: st,
-			}
+			t := sched.newTask(os.Stdin.Name())
+			t.r = os.Stdin
+			err = sched.submit(t)
+
+		default:
+			err = filepath.WalkDir(v, sched.walkFunc(v))
+		}
 
-			if err := st.run(rw, red); err != nil {
-				log.Fatal().Str("path", v).Msg(err.Error())
+		if err != nil {
+			if e := sched.wait(); e != nil {
+				err = e
 			}
+			st.fatal(v, err)
+		}
+	}
 
-		default:
-			if err := filepath.WalkDir(v, st.walkFunc(red)); err != nil {
-				log.Fatal().Str("path", v).Msg(err.Error())
+	if err := sched.wait(); err != nil {
+		st.fatal("", err)
+	}
+
+	if err := st.saveVault(); err != nil {
+		st.fatal(st.vaultFile, err)
+	}
+
+	if st.backup != nil {
+		if err := st.backup.Close(); err != nil {
+			st.fatal("", err)
+		}
+	}
+
+	st.writeStats()
+
+	if err := st.writeReport(); err != nil {
+		st.fatal("", err)
+	}
+
+	if st.check && st.found {
+		os.Exit(statusFound)
+	}
+}
+
+// method returns the Replacer for a redaction method. Keyed methods
+// share the key and the token method shares the vault. In check mode,
+// the vault is neither read nor written: tokens are discarded.
+func (st *state) method(s string) (overwrite.Replacer, error) {
+	name, _, _ := strings.Cut(s, ":")
+
+	switch name {
+	case "fake":
+		// The key is optional: without a key, values are random.
+		if st.key == nil {
+			key, err := readKey(st.keyFile)
+			if err != nil && st.keyFile != "" {
+				return nil, err
 			}
+			st.key = key
+		}
+	case "hmac":
+		if st.key == nil {
+			key, err := readKey(st.keyFile)
+			if err != nil {
+				return nil, err
+			}
+			st.key = key
+		}
+	case "token":
+		if s != name {
+			return nil, fmt.Errorf("%s: %w", s, overwrite.ErrMethod)
 		}
+
+		if st.check {
+			return vault.New(), nil
+		}
+
+		if st.vault != nil {
+			return st.vault, nil
+		}
+
+		if st.vaultFile == "" {
+			return nil, errNoVault
+		}
+
+		key, err := readKey(st.keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := vault.Load(st.vaultFile, key)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			v = vault.New()
+		case err != nil:
+			return nil, err
+		}
+
+		st.key = key
+		st.vault = v
+
+		return v, nil
 	}
+
+	return overwrite.Parse(s, st.substitute, st.key)
 }
 
-func (st *state) walkFunc(red *redact.Opt) fs.WalkDirFunc {
+// fatal logs the error and exits. In check mode, the exit status
+// distinguishes errors from secrets.
+func (st *state) fatal(path string, err error) {
+	ev := log.WithLevel(zerolog.FatalLevel)
+	if path != "" {
+		ev = ev.Str("path", path)
+	}
+	ev.Msg(err.Error())
+
+	// Files redacted before the error contain tokens of the vault.
+	if err := st.saveVault(); err != nil {
+		log.Error().Str("path", st.vaultFile).Msg(err.Error())
+	}
+
+	st.writeStats()
+
+	if st.check {
+		os.Exit(statusError)
+	}
+	os.Exit(1)
+}
+
+// saveVault writes the vault, if tokens were used, to the vault file. The
+// vault is saved at most once.
+func (st *state) saveVault() error {
+	if st.vault == nil {
+		return nil
+	}
+
+	v := st.vault
+	st.vault = nil
+
+	return v.Save(st.vaultFile, st.key)
+}
+
+// writeStats writes the summary of the run to stderr.
+func (st *state) writeStats() {
+	if st.stats == nil {
+		return
+	}
+
+	if err := st.stats.write(os.Stderr, st.statsFormat); err != nil {
+		log.Error().Msg(err.Error())
+	}
+}
+
+// walkFunc returns the function visiting the files of the directory
+// tree at root. Files are excluded by --skip, by ignore files and by
+// --include. The backup directory and the vault are always excluded.
+func (s *scheduler) walkFunc(root string) fs.WalkDirFunc {
+	var m ignore.Matcher
+
 	return func(path string, de fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("%s: %w", path, err)
 		}
 
-		for _, pattern := range st.skip {
-			matched, err := filepath.Match(filepath.Join(filepath.Dir(path), pattern), path)
-			if err != nil {
-				return fmt.Errorf("%s: %w", path, err)
+		t := s.newTask(path)
+		t.root = root
+
+		if s.excluded(path) {
+			t.skipped = true
+			t.log.Info().Str("path", path).Msg("excluded")
+			if err := s.submit(t); err != nil {
+				return err
+			}
+			if de.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		pattern, err := s.skipped(&t.log, path)
+		if err != nil {
+			return err
+		}
+
+		if pattern != "" {
+			t.skipped = true
+
+			if !de.IsDir() {
+				return s.submit(t)
 			}
 
-			if !matched {
-				log.Debug().Str("path", path).Str("match", pattern).Msg("glob")
-				continue
+			t.log.Warn().Str("path", path).Str("match", pattern).Msg("skipped")
+			if err := s.submit(t); err != nil {
+				return err
 			}
+			return filepath.SkipDir
+		}
+
+		rel := relPath(root, path, de)
 
+		if rel != "." && m.Match(rel, de.IsDir()) {
+			t.skipped = true
+			t.log.Info().Str("path", path).Msg("ignored")
+			if err := s.submit(t); err != nil {
+				return err
+			}
 			if de.IsDir() {
-				log.Warn().Str("path", path).Str("match", pattern).Msg("skipped")
 				return filepath.SkipDir
 			}
-
 			return nil
 		}
 
+		if de.IsDir() {
+			if err := s.readIgnore(&m, path, rel); err != nil {
+				return err
+			}
+		}
+
 		if de.Type() != 0 {
-			return nil
+			return s.submit(t)
+		}
+
+		if !s.included(rel) {
+			t.skipped = true
+			t.log.Debug().Str("path", path).Msg("not included")
+			return s.submit(t)
 		}
 
-		log.Info().Str("path", path).Msg("matched")
+		t.log.Info().Str("path", path).Msg("matched")
 
 		r, err := os.Open(path)
 		if err != nil {
 			return fmt.Errorf("%s: %w", path, err)
 		}
 
+		t.r = r
+
+		return s.submit(t)
+	}
+}
+
+// skipped returns the pattern excluding the path from a directory walk.
+func (st *state) skipped(l *zerolog.Logger, path string) (string, error) {
+	for _, pattern := range st.skip {
+		matched, err := filepath.Match(filepath.Join(filepath.Dir(path), pattern), path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+
+		if matched {
+			return pattern, nil
+		}
+
+		l.Debug().Str("path", path).Str("match", pattern).Msg("glob")
+	}
+
+	return "", nil
+}
+
+// excluded reports whether the path is the backup directory or the vault.
+func (st *state) excluded(path string) bool {
+	if len(st.exclude) == 0 {
+		return false
+	}
+
+	abs := absPath(path)
+	for _, v := range st.exclude {
+		if abs == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// process redacts the file for the task.
+func (st *state) process(ctx context.Context, red *redact.Opt, t *task) {
+	defer close(t.done)
+
+	if t.r != os.Stdin {
 		defer func() {
-			err = errors.Join(err, r.Close())
+			t.err = errors.Join(t.err, t.r.Close())
 		}()
+	}
 
-		rw := &fsobj{
-			r:     r,
-			state: st,
-		}
+	var rw fdpair.FD
 
-		return st.run(rw, red)
+	switch {
+	case st.check:
+		rw = &discard{r: t.r}
+	case st.diff, t.r == os.Stdin:
+		rw = &stdio{r: t.r, w: t.stdout}
+	default:
+		rw = &fsobj{r: t.r, state: st, stdout: t.stdout}
 	}
+
+	t.results, t.err = st.run(t.log.WithContext(ctx), rw, red)
 }
 
-func (st *state) run(rw fdpair.FD, red *redact.Opt) error {
+func (st *state) run(ctx context.Context, rw fdpair.FD, red *redact.Opt) (r []result, err error) {
 	in := ""
 
 	if f, ok := rw.In().(*os.File); ok {
 		in = f.Name()
 	}
 
-	err := rw.Open()
-	if err != nil {
-		return fmt.Errorf("%s: %w", in, err)
+	if err := rw.Open(); err != nil {
+		return nil, fmt.Errorf("%s: %w", in, err)
 	}
 
 	defer func() {
 		err = errors.Join(err, rw.Close())
 	}()
 
-	b, err := io.ReadAll(rw.In())
-	if err != nil {
-		return fmt.Errorf("%s: %w", in, err)
+	size := st.archiveSize
+
+	r, err = st.redact(ctx, red, in, bufio.NewReaderSize(rw.In(), sniffSize), rw.Out(), 0, &size)
+
+	if f, ok := rw.(*fsobj); ok {
+		f.modified = len(r) > 0
 	}
 
-	s, err := red.Redact(string(b))
-	if err != nil {
-		return fmt.Errorf("%s: %w", in, err)
+	return r, err
+}
+
+// redact removes secrets from the file. Compressed files and archives are
+// detected by their first bytes. depth is the number of compressed files
+// and archives containing the file and size the number of bytes which may
+// still be read from them.
+func (st *state) redact(ctx context.Context, red *redact.Opt, name string, r *bufio.Reader, w io.Writer, depth int, size *int64) ([]result, error) {
+	b, _ := r.Peek(sniffSize)
+
+	red = red.ForFile(absPath(name))
+
+	if c, ok := sniffCodec(b); ok {
+		if depth >= st.codecDepth() {
+			return nil, fmt.Errorf("%s: %w", name, errArchiveDepth)
+		}
+		return st.decompress(ctx, red, c, name, r, w, depth+1, size)
 	}
 
-	out := ""
+	if st.archiveDepth > 0 {
+		if kind := sniffArchive(b); kind != notArchive {
+			if depth >= st.archiveDepth {
+				return nil, fmt.Errorf("%s: %w", name, errArchiveDepth)
+			}
+			return st.archive(ctx, red, kind, name, r, w, depth+1, size)
+		}
+	}
 
-	if f, ok := rw.Out().(*os.File); ok {
-		out = f.Name()
+	if st.binary != binaryScan && binary(b) {
+		zerolog.Ctx(ctx).Info().Str("path", name).Str("policy", st.binary).Msg("binary")
+		return st.binaryFile(name, r, w)
 	}
 
-	if _, err := io.WriteString(rw.Out(), s); err != nil {
-		return fmt.Errorf("%s: %w", out, err)
+	fn, structured := handler(ctx, red, name)
+
+	var findings []redact.Finding
+	var err error
+
+	switch {
+	case st.diff:
+		findings, err = st.unidiff(fn, name, r, w)
+	case structured:
+		findings, err = document(fn, r, w)
+	default:
+		findings, err = red.RedactStreamWithFindings(ctx, r, w)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
 
-	return nil
+	return results(name, findings), nil
 }
 
 func readRules(s string) ([]byte, error) {
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// copyMeta copies the ownership, extended attributes, mode and
+// timestamps of the original file to the redacted file. The owner is
+// only changed if permitted.
+func copyMeta(dst *os.File, src string, fi fs.FileInfo) error {
+	if err := chown(dst, fi); err != nil {
+		return fmt.Errorf("%s: %w", dst.Name(), err)
+	}
+
+	// Extended attributes are copied before the mode: the original file
+	// may be read-only.
+	if err := copyXattrs(dst.Name(), src); err != nil {
+		return fmt.Errorf("%s: %w", dst.Name(), err)
+	}
+
+	mode := fi.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if err := dst.Chmod(mode); err != nil {
+		return fmt.Errorf("%s: %w", dst.Name(), err)
+	}
+
+	if err := os.Chtimes(dst.Name(), atime(fi), fi.ModTime()); err != nil {
+		return fmt.Errorf("%s: %w", dst.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFsobj_preserveOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner requires root")
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+
+	if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := os.Chown(path, 1234, 5678); err != nil {
+		t.Fatalf("chown: %v", err)
+	}
+
+	redactInPlace(t, path, "**REDACTED**\n")
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	st := fi.Sys().(*syscall.Stat_t)
+	if st.Uid != 1234 || st.Gid != 5678 {
+		t.Errorf("owner: %d:%d, expected 1234:5678", st.Uid, st.Gid)
+	}
+}
+
+func TestFsobj_preserveXattrs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+
+	if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	err := unix.Setxattr(path, "user.redact", []byte("value"), 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		t.Skip("extended attributes are not supported")
+	}
+	if err != nil {
+		t.Fatalf("setxattr: %v", err)
+	}
+
+	selinux, _ := xattr(func(b []byte) (int, error) {
+		return unix.Getxattr(path, "security.selinux", b)
+	})
+
+	redactInPlace(t, path, "**REDACTED**\n")
+
+	v, err := xattr(func(b []byte) (int, error) {
+		return unix.Getxattr(path, "user.redact", b)
+	})
+	if err != nil {
+		t.Fatalf("getxattr: %v", err)
+	}
+
+	if string(v) != "value" {
+		t.Errorf("user.redact: %q, expected %q", v, "value")
+	}
+
+	if selinux != nil {
+		label, err := xattr(func(b []byte) (int, error) {
+			return unix.Getxattr(path, "security.selinux", b)
+		})
+		if err != nil || string(label) != string(selinux) {
+			t.Errorf("security.selinux: %q, expected %q", label, selinux)
+		}
+	}
+}
+
+func TestFsobj_crossDevice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The temporary file must not be created in TMPDIR, which may be a
+	// different file system than the file: use a file system other than
+	// the directory of the file if available, otherwise a directory
+	// which doesn't exist.
+	tmp := filepath.Join(dir, "missing")
+	if fi, err := os.Stat("/dev/shm"); err == nil && fi.IsDir() && !sameDevice(t, dir, "/dev/shm") {
+		tmp = "/dev/shm"
+	}
+	t.Setenv("TMPDIR", tmp)
+
+	redactInPlace(t, path, "**REDACTED**\n")
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if string(b) != "**REDACTED**\n" {
+		t.Errorf("content: %q", b)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("temporary file not removed: %v", entries)
+	}
+}
+
+func sameDevice(t *testing.T, a, b string) bool {
+	t.Helper()
+
+	var x, y syscall.Stat_t
+
+	if err := syscall.Stat(a, &x); err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	if err := syscall.Stat(b, &y); err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	return x.Dev == y.Dev
+}
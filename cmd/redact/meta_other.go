@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+func chown(*os.File, fs.FileInfo) error {
+	return nil
+}
+
+// syncDir is not supported: directories can't be synced on this
+// platform.
+func syncDir(string) error {
+	return nil
+}
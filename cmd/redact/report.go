@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.iscode.ca/redact/pkg/redact"
+)
+
+// Formats of the report set by --report-format.
+const (
+	reportJSON  = "json"
+	reportSARIF = "sarif"
+)
+
+// result is a finding in a file.
+type result struct {
+	Path string `json:"path"`
+	redact.Finding
+}
+
+// results returns the findings in the file at path.
+func results(path string, findings []redact.Finding) []result {
+	r := make([]result, 0, len(findings))
+	for _, v := range findings {
+		r = append(r, result{Path: path, Finding: v})
+	}
+	return r
+}
+
+func (st *state) record(r []result) {
+	if len(r) > 0 {
+		st.found = true
+	}
+
+	st.results = append(st.results, r...)
+}
+
+func (st *state) writeReport() error {
+	if st.report == "" {
+		return nil
+	}
+
+	var v any = st.results
+	if st.reportFormat == reportSARIF {
+		v = sarif(st.results)
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", st.report, err)
+	}
+
+	if err := os.WriteFile(st.report, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("%s: %w", st.report, err)
+	}
+
+	return nil
+}
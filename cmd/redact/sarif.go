@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// SARIF 2.1.0 log: only the properties describing findings are set.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion locates the secret by line and column and by byte offset.
+// Columns count UTF-16 code units.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
+
+// sarif returns the findings as a SARIF log. The secret is not included:
+// a result is identified by a gitleaks fingerprint, the path, rule ID and
+// line of the secret.
+func sarif(results []result) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "redact",
+				Version:        version,
+				InformationURI: "https://go.iscode.ca/redact",
+				Rules:          make([]sarifRule, 0),
+			},
+		},
+		ColumnKind: "utf16CodeUnits",
+		Results:    make([]sarifResult, 0, len(results)),
+	}
+
+	index := make(map[string]int)
+
+	for _, v := range results {
+		n, ok := index[v.RuleID]
+		if !ok {
+			n = len(run.Tool.Driver.Rules)
+			index[v.RuleID] = n
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               v.RuleID,
+				ShortDescription: sarifMessage{Text: v.Description},
+			})
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    v.RuleID,
+			RuleIndex: n,
+			Level:     "error",
+			Message:   sarifMessage{Text: v.Description},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: fileURI(v.Path)},
+					Region: sarifRegion{
+						StartLine:   v.StartLine,
+						StartColumn: v.StartColumnUTF16,
+						EndLine:     v.EndLine,
+						EndColumn:   v.EndColumnUTF16,
+						ByteOffset:  v.Start,
+						ByteLength:  v.End - v.Start,
+					},
+				},
+			}},
+			PartialFingerprints: map[string]string{
+				"gitleaksFingerprint/v1": fmt.Sprintf("%s:%s:%d", filepath.ToSlash(v.Path), v.RuleID, v.StartLine),
+			},
+		})
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+}
+
+// fileURI returns the URI of the path: a relative reference for
+// relative paths, otherwise a file URI.
+func fileURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+		if filepath.VolumeName(path) != "" {
+			u.Path = "/" + u.Path
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// statsFormat is the format of the summary set by --stats: --stats
+// writes text and --stats=json writes JSON.
+type statsFormat string
+
+const (
+	statsText = "text"
+	statsJSON = "json"
+)
+
+func (f *statsFormat) String() string {
+	return string(*f)
+}
+
+func (f *statsFormat) Set(s string) error {
+	switch s {
+	case "true", statsText:
+		*f = statsText
+	case "false", "":
+		*f = ""
+	case statsJSON:
+		*f = statsJSON
+	default:
+		return fmt.Errorf("invalid stats format: %s", s)
+	}
+	return nil
+}
+
+func (f *statsFormat) IsBoolFlag() bool {
+	return true
+}
+
+// counts are the totals for a set of files.
+type counts struct {
+	// Scanned is the number of files read. Skipped is the number of
+	// files and directories excluded by --skip, ignore files and
+	// --include.
+	Scanned int `json:"scanned"`
+	Skipped int `json:"skipped"`
+
+	// Modified is the number of files containing secrets: the files
+	// modified by -i or which would be modified without -i.
+	Modified int `json:"modified"`
+	Failed   int `json:"failed"`
+	Findings int `json:"findings"`
+}
+
+// stats summarizes a run.
+type stats struct {
+	counts
+
+	// Rules is the number of findings by rule ID.
+	Rules map[string]int `json:"rules"`
+
+	// Directories are the totals by top-level directory of each path
+	// on the command line.
+	Directories map[string]*counts `json:"directories"`
+}
+
+func newStats() *stats {
+	return &stats{
+		Rules:       make(map[string]int),
+		Directories: make(map[string]*counts),
+	}
+}
+
+// add counts the task.
+func (s *stats) add(t *task) {
+	dir := s.Directories[topLevel(t.root, t.path)]
+	if dir == nil {
+		dir = &counts{}
+		s.Directories[topLevel(t.root, t.path)] = dir
+	}
+
+	for _, c := range []*counts{&s.counts, dir} {
+		switch {
+		case t.err != nil:
+			c.Failed++
+		case t.skipped:
+			c.Skipped++
+		case t.r == nil:
+			continue
+		default:
+			c.Scanned++
+			if len(t.results) > 0 {
+				c.Modified++
+			}
+			c.Findings += len(t.results)
+		}
+	}
+
+	if t.err == nil {
+		for _, v := range t.results {
+			s.Rules[v.RuleID]++
+		}
+	}
+}
+
+// topLevel returns the top-level directory of the path in the directory
+// tree at root. Files in root are counted in root.
+func topLevel(root, path string) string {
+	if root == "" {
+		return path
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return root
+	}
+
+	dir, _, ok := strings.Cut(rel, string(filepath.Separator))
+	if !ok {
+		return root
+	}
+
+	return filepath.Join(root, dir)
+}
+
+func (s *stats) write(w io.Writer, format statsFormat) error {
+	if format == statsJSON {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(append(b, '\n'))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "scanned:\t%d\nskipped:\t%d\nmodified:\t%d\nfailed:\t%d\nfindings:\t%d\n",
+		s.Scanned, s.Skipped, s.Modified, s.Failed, s.Findings)
+
+	if len(s.Rules) > 0 {
+		fmt.Fprintf(tw, "\nRULE\tFINDINGS\n")
+		for _, id := range sortedKeys(s.Rules) {
+			fmt.Fprintf(tw, "%s\t%d\n", id, s.Rules[id])
+		}
+	}
+
+	if len(s.Directories) > 0 {
+		fmt.Fprintf(tw, "\nDIRECTORY\tSCANNED\tSKIPPED\tMODIFIED\tFAILED\tFINDINGS\n")
+		for _, dir := range sortedKeys(s.Directories) {
+			c := s.Directories[dir]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", dir, c.Scanned, c.Skipped, c.Modified, c.Failed, c.Findings)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTopLevel(t *testing.T) {
+	tests := []struct {
+		root, path, expected string
+	}{
+		{".", "file", "."},
+		{".", "src/a/file", "src"},
+		{"src", "src/a/b/file", filepath.Join("src", "a")},
+		{"src/file", "src/file", "src/file"},
+		{"", "/dev/stdin", "/dev/stdin"},
+	}
+
+	for _, v := range tests {
+		if s := topLevel(v.root, filepath.FromSlash(v.path)); s != filepath.FromSlash(v.expected) {
+			t.Errorf("%s in %s: %s, expected %s", v.path, v.root, s, v.expected)
+		}
+	}
+}
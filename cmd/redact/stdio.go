@@ -6,14 +6,11 @@ import (
 )
 
 type stdio struct {
-	*state
-
 	r *os.File
-	w *os.File
+	w io.Writer
 }
 
 func (rw *stdio) Open() error {
-	rw.w = os.Stdout
 	return nil
 }
 
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.iscode.ca/redact/internal/pkg/seal"
+)
+
+var errNotRestored = errors.New("not restored: files modified or removed since redaction")
+
+func undoUsage(fl *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, `%s v%s
+Usage: %s undo [<option>] <journal>
+
+Restore files redacted in-place using --backup-dir. A file is restored
+only if its content is unmodified since redaction.
+
+Examples:
+
+  redact -i --backup-dir backup .
+  redact undo backup/journal.jsonl
+
+Options:
+
+`, path.Base(os.Args[0]), version, os.Args[0])
+		fl.PrintDefaults()
+	}
+}
+
+func undo(args []string) {
+	envKeyFile := getenv("REDACT_KEY_FILE", "")
+	envLogLevel := getenv("REDACT_LOG_LEVEL", zerolog.LevelErrorValue)
+
+	fl := flag.NewFlagSet("undo", flag.ExitOnError)
+
+	keyFile := fl.String("key-file", envKeyFile, "Path to file containing the key of encrypted backups")
+	logLevel := fl.String("log-level", envLogLevel, "Set log level")
+
+	fl.Usage = undoUsage(fl)
+	_ = fl.Parse(args)
+
+	if fl.NArg() != 1 {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	l, err := zerolog.ParseLevel(*logLevel)
+	if err != nil {
+		fl.Usage()
+		os.Exit(2)
+	}
+	zerolog.SetGlobalLevel(l)
+
+	st := &state{
+		inplace: true,
+		keyFile: *keyFile,
+	}
+
+	if err := st.undo(fl.Arg(0)); err != nil {
+		st.fatal(fl.Arg(0), err)
+	}
+}
+
+// readJournal returns the entries of the journal.
+func readJournal(name string) ([]entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]entry, 0)
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var e entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, sc.Err()
+}
+
+// undo restores the files recorded in the journal, most recent first: a
+// file redacted by several runs is restored to its oldest version.
+func (st *state) undo(journal string) error {
+	entries, err := readJournal(journal)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(journal)
+	skipped := 0
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		current, err := fileDigest(e.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Error().Str("path", e.Path).Msg("not restored: file removed")
+			skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+
+		if current != e.Redacted {
+			log.Error().Str("path", e.Path).Msg("not restored: file modified since redaction")
+			skipped++
+			continue
+		}
+
+		if err := st.restoreBackup(e, dir); err != nil {
+			return err
+		}
+
+		log.Info().Str("path", e.Path).Msg("restored")
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%d of %d files: %w", skipped, len(entries), errNotRestored)
+	}
+
+	return nil
+}
+
+// restoreBackup restores the file recorded in the journal entry from
+// its backup in dir.
+func (st *state) restoreBackup(e entry, dir string) (err error) {
+	name := filepath.Join(dir, e.Backup)
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	r, err := st.readBackup(f, e.Encrypted)
+	if err != nil {
+		return err
+	}
+
+	return st.restoreFile(e.Path, r, e.Original)
+}
+
+// readBackup returns the reader of the original content of a file,
+// decrypting it if encrypted.
+func (st *state) readBackup(f *os.File, encrypted bool) (io.Reader, error) {
+	if !encrypted {
+		return f, nil
+	}
+
+	if st.key == nil {
+		key, err := readKey(st.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		st.key = key
+	}
+
+	r, err := seal.NewReader(f, st.key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+
+	return r, nil
+}
+
+// restoreFile replaces the content of the file with the content read
+// from r, preserving its metadata. The file is only replaced if the
+// content matches the digest.
+func (st *state) restoreFile(name string, r io.Reader, digest string) (err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	rw := &fsobj{state: st, r: f}
+
+	if err := rw.Open(); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+
+	if _, err := io.Copy(io.MultiWriter(rw.Out(), h), r); err != nil {
+		return errors.Join(fmt.Errorf("%s: %w", name, err), rw.Close())
+	}
+
+	rw.modified = hex.EncodeToString(h.Sum(nil)) == digest
+	if !rw.modified {
+		return errors.Join(fmt.Errorf("%s: %w", name, seal.ErrInvalid), rw.Close())
+	}
+
+	return rw.Close()
+}
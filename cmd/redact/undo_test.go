@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	// The original is larger than a segment of an encrypted backup.
+	original := strings.Repeat("secret\n", 20000)
+
+	for _, key := range [][]byte{nil, []byte("key")} {
+		dir := t.TempDir()
+		backupDir := filepath.Join(dir, "backup")
+
+		b, err := openBackup(backupDir, key)
+		if err != nil {
+			t.Fatalf("backup: %v", err)
+		}
+
+		files := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+
+		st := &state{inplace: true, backup: b}
+
+		for _, name := range files {
+			if err := os.WriteFile(name, []byte(original), 0o640); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			redactFile(t, st, name, "**REDACTED**\n")
+		}
+
+		if err := b.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		// A file modified after redaction is not restored.
+		if err := os.WriteFile(files[1], []byte("modified\n"), 0o640); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		st = &state{inplace: true, key: key}
+
+		err = st.undo(filepath.Join(backupDir, journalName))
+		if !errors.Is(err, errNotRestored) {
+			t.Errorf("undo: %v", err)
+		}
+
+		entries, err := os.ReadDir(backupDir)
+		if err != nil {
+			t.Fatalf("readdir: %v", err)
+		}
+
+		// Both files share a backup, without temporary files.
+		if len(entries) != 2 {
+			t.Errorf("backups: %v", entries)
+		}
+
+		for i, expected := range []string{original, "modified\n"} {
+			v, err := os.ReadFile(files[i])
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+
+			if string(v) != expected {
+				t.Errorf("%s: %d bytes, expected %d", files[i], len(v), len(expected))
+			}
+		}
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backup")
+
+	st := &state{exclude: []string{absPath(backupDir)}}
+
+	for path, expected := range map[string]bool{
+		backupDir:                         true,
+		filepath.Join(dir, ".", "backup"): true,
+		filepath.Join(backupDir, "file"):  false,
+		filepath.Join(dir, "file"):        false,
+	} {
+		if v := st.excluded(path); v != expected {
+			t.Errorf("%s: %v, expected %v", path, v, expected)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.iscode.ca/redact/internal/pkg/fdpair"
+	"go.iscode.ca/redact/pkg/redact/vault"
+)
+
+var errNoVault = errors.New("no vault: use --vault")
+
+func unredactUsage(fl *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, `%s v%s
+Usage: %s unredact [<option>] <file|directory|-> <...>
+
+Restore secrets replaced by tokens using --remove=token.
+
+Examples:
+
+  # restore secrets in-place
+  redact unredact --vault secrets.vault -i file.txt
+
+  # from stdin
+  cat redacted | redact unredact --vault secrets.vault -
+
+Options:
+
+`, path.Base(os.Args[0]), version, os.Args[0])
+		fl.PrintDefaults()
+	}
+}
+
+func unredact(args []string) {
+	envSkip := getenv("REDACT_SKIP", ".git .gitleaks.toml")
+	envKeyFile := getenv("REDACT_KEY_FILE", "")
+	envVault := getenv("REDACT_VAULT", "")
+	envLogLevel := getenv("REDACT_LOG_LEVEL", zerolog.LevelErrorValue)
+
+	envInPlace := getenvbool("REDACT_INPLACE")
+
+	fl := flag.NewFlagSet("unredact", flag.ExitOnError)
+
+	keyFile := fl.String("key-file", envKeyFile, "Path to file containing the vault key")
+	vaultFile := fl.String("vault", envVault, "Path to the encrypted file storing secrets replaced by tokens")
+	logLevel := fl.String("log-level", envLogLevel, "Set log level")
+	skip := fl.String("skip", envSkip, "Skip glob matches in directories")
+	fl.StringVar(skip, "S", envSkip, "Skip glob matches in directories")
+
+	inplace := fl.Bool("inplace", envInPlace, "Restore the file in-place")
+	fl.BoolVar(inplace, "i", envInPlace, "Restore the file in-place")
+
+	fl.Usage = unredactUsage(fl)
+	_ = fl.Parse(args)
+
+	if fl.NArg() == 0 {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	l, err := zerolog.ParseLevel(*logLevel)
+	if err != nil {
+		fl.Usage()
+		os.Exit(2)
+	}
+	zerolog.SetGlobalLevel(l)
+
+	st := &state{
+		inplace: *inplace,
+		skip:    strings.Fields(*skip),
+	}
+
+	if *vaultFile == "" {
+		st.fatal("", errNoVault)
+	}
+
+	key, err := readKey(*keyFile)
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	v, err := vault.Load(*vaultFile, key)
+	if err != nil {
+		st.fatal("", err)
+	}
+
+	for _, arg := range fl.Args() {
+		switch arg {
+		case "-":
+			if err := restore(v, &stdio{r: os.Stdin, w: os.Stdout}); err != nil {
+				st.fatal(arg, err)
+			}
+		default:
+			if err := filepath.WalkDir(arg, st.restoreFunc(v)); err != nil {
+				st.fatal(arg, err)
+			}
+		}
+	}
+}
+
+func (st *state) restoreFunc(v *vault.Vault) fs.WalkDirFunc {
+	return func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		pattern, err := st.skipped(&log.Logger, path)
+		if err != nil {
+			return err
+		}
+
+		if pattern != "" {
+			if de.IsDir() {
+				log.Warn().Str("path", path).Str("match", pattern).Msg("skipped")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if de.Type() != 0 {
+			return nil
+		}
+
+		log.Info().Str("path", path).Msg("matched")
+
+		r, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		err = restore(v, &fsobj{r: r, state: st, stdout: os.Stdout})
+
+		return errors.Join(err, r.Close())
+	}
+}
+
+// restore replaces tokens in the input with the secrets stored in the
+// vault. Tokens do not span lines: the input is processed line by line.
+// A file without tokens is left untouched.
+func restore(v *vault.Vault, rw fdpair.FD) (err error) {
+	in := ""
+
+	if f, ok := rw.In().(*os.File); ok {
+		in = f.Name()
+	}
+
+	if err := rw.Open(); err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	defer func() {
+		err = errors.Join(err, rw.Close())
+	}()
+
+	r := bufio.NewReader(rw.In())
+	w := bufio.NewWriter(rw.Out())
+
+	for {
+		line, err := r.ReadString('\n')
+
+		s := v.Unredact(line)
+		if f, ok := rw.(*fsobj); ok && s != line {
+			f.modified = true
+		}
+
+		if _, err := io.WriteString(w, s); err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	return nil
+}
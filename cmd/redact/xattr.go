@@ -0,0 +1,73 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of the file src to dst,
+// including security labels such as security.selinux. Attributes which
+// can't be set by the user are ignored.
+func copyXattrs(dst, src string) error {
+	names, err := xattr(func(b []byte) (int, error) {
+		return unix.Listxattr(src, b)
+	})
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		v, err := xattr(func(b []byte) (int, error) {
+			return unix.Getxattr(src, string(name), b)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = unix.Setxattr(dst, string(name), v, 0)
+		switch {
+		case errors.Is(err, unix.EPERM), errors.Is(err, unix.EACCES), errors.Is(err, unix.ENOTSUP):
+		case err != nil:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xattr calls fn with a buffer large enough for the value.
+func xattr(fn func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := fn(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			return nil, nil
+		}
+
+		b := make([]byte, n)
+		n, err = fn(b)
+		if errors.Is(err, unix.ERANGE) {
+			// The value grew between the calls.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return b[:n], nil
+	}
+}
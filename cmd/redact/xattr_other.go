@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package main
+
+func copyXattrs(dst, src string) error {
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package ignore matches paths against gitignore patterns.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches paths against patterns read from ignore files in a
+// directory tree. Paths are slash separated and relative to the root of
+// the tree.
+type Matcher struct {
+	rules []rule
+}
+
+// rule is a pattern read from an ignore file in the directory base.
+type rule struct {
+	base   string
+	re     *regexp.Regexp
+	negate bool
+	dir    bool
+}
+
+// Add reads the patterns of an ignore file located in the directory
+// base. Patterns added later take precedence.
+func (m *Matcher) Add(base string, r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		m.AddPatterns(base, sc.Text())
+	}
+	return sc.Err()
+}
+
+// AddPatterns adds patterns relative to the directory base.
+func (m *Matcher) AddPatterns(base string, patterns ...string) {
+	for _, p := range patterns {
+		if r, ok := parse(base, p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// Match reports whether the path is ignored: the last pattern matching
+// the path decides, a pattern prefixed by "!" re-including the path.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	ignored := false
+
+	for _, r := range m.rules {
+		if r.dir && !isDir {
+			continue
+		}
+
+		rel, ok := relative(r.base, name)
+		if !ok {
+			continue
+		}
+
+		if r.re.MatchString(rel) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+// relative returns the path relative to the directory base.
+func relative(base, name string) (string, bool) {
+	if base == "" || base == "." {
+		return name, true
+	}
+
+	if rel, ok := strings.CutPrefix(name, base+"/"); ok {
+		return rel, true
+	}
+
+	return "", false
+}
+
+// parse converts a line of an ignore file to a rule. Blank lines and
+// comments are not rules.
+func parse(base, s string) (rule, bool) {
+	r := rule{base: path.Clean(base)}
+
+	s = strings.TrimSuffix(s, "\r")
+	s = trimSpace(s)
+
+	if s == "" || strings.HasPrefix(s, "#") {
+		return rule{}, false
+	}
+
+	if rest, ok := strings.CutPrefix(s, "!"); ok {
+		r.negate = true
+		s = rest
+	}
+
+	if rest, ok := strings.CutSuffix(s, "/"); ok {
+		r.dir = true
+		s = rest
+	}
+
+	// A pattern containing a slash is relative to the directory of the
+	// ignore file, otherwise it matches a name at any level.
+	if strings.Contains(s, "/") {
+		s = strings.TrimPrefix(s, "/")
+	} else {
+		s = "**/" + s
+	}
+
+	if s == "" {
+		return rule{}, false
+	}
+
+	re, err := regexp.Compile("^" + translate(s) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+
+	r.re = re
+
+	return r, true
+}
+
+// trimSpace removes trailing spaces unless escaped with a backslash.
+func trimSpace(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// translate converts a gitignore pattern to a regular expression.
+func translate(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*':
+			if !strings.HasPrefix(s[i:], "**") {
+				b.WriteString(`[^/]*`)
+				continue
+			}
+
+			// "**" matches any number of directories if it is a
+			// whole path component.
+			start := i == 0 || s[i-1] == '/'
+			switch end := s[i+2:]; {
+			case start && end == "":
+				b.WriteString(`.*`)
+				i++
+			case start && strings.HasPrefix(end, "/"):
+				b.WriteString(`(?:.*/)?`)
+				i += 2
+			default:
+				b.WriteString(`[^/]*`)
+				i++
+			}
+		case '?':
+			b.WriteString(`[^/]`)
+		case '[':
+			n := strings.IndexByte(s[i+1:], ']')
+			if n < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+
+			class := s[i+1 : i+1+n]
+			if n == 0 {
+				// "[]...]" includes a closing bracket.
+				m := strings.IndexByte(s[i+2:], ']')
+				if m < 0 {
+					b.WriteString(`\[`)
+					continue
+				}
+				class = s[i+1 : i+2+m]
+				n = m + 1
+			}
+
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += n + 1
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package ignore_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.iscode.ca/redact/internal/pkg/ignore"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	var m ignore.Matcher
+
+	err := m.Add("", strings.NewReader(`# comment
+*.log
+!keep.log
+/build
+**/node_modules/**
+docs/**/*.txt
+tmp/
+secret?.[ch]
+\#hash
+`+"trailing\\ \n"))
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	if err := m.Add("sub", strings.NewReader("*.conf\n/local\n!keep.log\n")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"keep.log", false, false},
+		{"a/keep.log", false, false},
+		{"build", true, true},
+		{"a/build", true, false},
+		{"node_modules/x.js", false, true},
+		{"a/node_modules/b/x.js", false, true},
+		{"node_modules", true, false},
+		{"docs/a.txt", false, true},
+		{"docs/a/b/a.txt", false, true},
+		{"docs/a.md", false, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"a/tmp", true, true},
+		{"secret1.c", false, true},
+		{"secret12.c", false, false},
+		{"#hash", false, true},
+		{"comment", false, false},
+		{"trailing ", false, true},
+		{"sub/app.conf", false, true},
+		{"app.conf", false, false},
+		{"other/app.conf", false, false},
+		{"sub/local", false, true},
+		{"sub/a/local", false, false},
+	}
+
+	for _, v := range tests {
+		if got := m.Match(v.path, v.dir); got != v.ignored {
+			t.Errorf("%s: ignored=%v, expected %v", v.path, got, v.ignored)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package seal encrypts data using a key supplied by the user.
+//
+// The key is stretched using scrypt with a random salt. The data is
+// encrypted using AES-256-GCM. Streams are encrypted in segments, using
+// NewWriter and NewReader.
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+var (
+	magic = []byte("REDACT1\x00")
+
+	ErrInvalid = errors.New("invalid encrypted data")
+)
+
+// Seal encrypts and authenticates the plaintext.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(magic)+len(salt)+len(nonce)+len(plaintext)+aead.Overhead())
+	b = append(b, magic...)
+	b = append(b, salt...)
+	b = append(b, nonce...)
+
+	return aead.Seal(b, nonce, plaintext, magic), nil
+}
+
+// Open decrypts and authenticates the ciphertext.
+func Open(key, ciphertext []byte) ([]byte, error) {
+	b, ok := bytes.CutPrefix(ciphertext, magic)
+	if !ok || len(b) < saltSize {
+		return nil, ErrInvalid
+	}
+
+	aead, err := newAEAD(key, b[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	b = b[saltSize:]
+	if len(b) < aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], magic)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key, salt []byte) (cipher.AEAD, error) {
+	k, err := scrypt.Key(key, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,209 @@
+package seal
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// segmentSize is the size of the plaintext of each segment of a stream.
+const segmentSize = 64 << 10
+
+// streamMagic starts a stream. The plaintext is encrypted in segments:
+// the nonce of a segment is a random prefix followed by the index of the
+// segment and a flag set for the last segment, so segments can't be
+// reordered, removed or truncated.
+var streamMagic = []byte("REDACT2\x00")
+
+var errTooLarge = errors.New("stream too large")
+
+type writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+}
+
+// NewWriter returns a writer encrypting and authenticating the plaintext
+// written to w, in segments to bound memory use. Close writes the last
+// segment: it does not close w.
+func NewWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, aead.NonceSize()-5)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(streamMagic)+len(salt)+len(prefix))
+	header = append(header, streamMagic...)
+	header = append(header, salt...)
+	header = append(header, prefix...)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, segmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (sw *writer) Write(p []byte) (int, error) {
+	n := 0
+
+	for len(p) > 0 {
+		// A full segment is written once more data follows: the last
+		// segment is written by Close.
+		if len(sw.buf) == segmentSize {
+			if err := sw.flush(false); err != nil {
+				return n, err
+			}
+		}
+
+		k := copy(sw.buf[len(sw.buf):segmentSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+k]
+		p = p[k:]
+		n += k
+	}
+
+	return n, nil
+}
+
+func (sw *writer) Close() error {
+	return sw.flush(true)
+}
+
+func (sw *writer) flush(last bool) error {
+	if sw.n == ^uint32(0) {
+		return errTooLarge
+	}
+
+	b := sw.aead.Seal(sw.buf[:0], nonce(sw.prefix, sw.n, last), sw.buf, streamMagic)
+	if _, err := sw.w.Write(b); err != nil {
+		return err
+	}
+
+	sw.buf = sw.buf[:0]
+	sw.n++
+
+	return nil
+}
+
+type reader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	seg    []byte
+	buf    []byte
+	done   bool
+}
+
+// NewReader returns a reader decrypting and authenticating the stream
+// written by NewWriter. Reading fails with ErrInvalid if the stream was
+// modified or truncated.
+func NewReader(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, len(streamMagic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalid
+	}
+
+	if string(header[:len(streamMagic)]) != string(streamMagic) {
+		return nil, ErrInvalid
+	}
+
+	aead, err := newAEAD(key, header[len(streamMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, aead.NonceSize()-5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrInvalid
+	}
+
+	return &reader{
+		r:      bufio.NewReaderSize(r, segmentSize+aead.Overhead()+1),
+		aead:   aead,
+		prefix: prefix,
+		seg:    make([]byte, segmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (sr *reader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+
+		if err := sr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+
+	return n, nil
+}
+
+// next decrypts the next segment. The last segment is followed by the
+// end of the stream.
+func (sr *reader) next() error {
+	n, err := io.ReadFull(sr.r, sr.seg)
+
+	last := false
+	switch {
+	case errors.Is(err, io.EOF):
+		return ErrInvalid
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := sr.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	b, err := sr.aead.Open(sr.seg[:0], nonce(sr.prefix, sr.n, last), sr.seg[:n], streamMagic)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	sr.buf = b
+	sr.done = last
+	sr.n++
+
+	return nil
+}
+
+// nonce returns the nonce of the nth segment.
+func nonce(prefix []byte, n uint32, last bool) []byte {
+	b := make([]byte, 0, len(prefix)+5)
+	b = append(b, prefix...)
+	b = binary.BigEndian.AppendUint32(b, n)
+
+	if last {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
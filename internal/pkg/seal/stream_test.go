@@ -0,0 +1,76 @@
+package seal_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"go.iscode.ca/redact/internal/pkg/seal"
+)
+
+func sealStream(t *testing.T, key, plaintext []byte) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	w, err := seal.NewWriter(&b, key)
+	if err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	return b.Bytes()
+}
+
+func openStream(key, ciphertext []byte) ([]byte, error) {
+	r, err := seal.NewReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	key := []byte("key")
+
+	for _, n := range []int{0, 1, 64 << 10, 64<<10 + 1, 200 << 10} {
+		plaintext := bytes.Repeat([]byte("secret\n"), n/7+1)[:n]
+
+		ciphertext := sealStream(t, key, plaintext)
+
+		b, err := openStream(key, ciphertext)
+		if err != nil {
+			t.Fatalf("%d: open: %v", n, err)
+		}
+
+		if !bytes.Equal(b, plaintext) {
+			t.Errorf("%d: plaintext differs", n)
+		}
+
+		if _, err := openStream([]byte("wrong key"), ciphertext); !errors.Is(err, seal.ErrInvalid) {
+			t.Errorf("%d: wrong key: %v", n, err)
+		}
+	}
+}
+
+func TestStream_truncated(t *testing.T) {
+	key := []byte("key")
+
+	// Three segments: the stream is truncated at the end of a segment,
+	// inside a segment and in the header.
+	ciphertext := sealStream(t, key, make([]byte, 150<<10))
+
+	for _, n := range []int{len(ciphertext) - (22<<10 + 16), len(ciphertext) - 1, 10} {
+		if _, err := openStream(key, ciphertext[:n]); !errors.Is(err, seal.ErrInvalid) {
+			t.Errorf("%d: %v, expected %v", n, err, seal.ErrInvalid)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package unidiff formats changes to a file as a unified diff.
+package unidiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Context is the number of unchanged lines surrounding a change.
+const Context = 3
+
+var ErrNotFound = errors.New("unable to align changed lines")
+
+// Region is a range of changed lines in the original file.
+type Region struct {
+	// Start and End are the 1-based first and last lines of the change.
+	Start int
+	End   int
+
+	// Labels annotate the hunk containing the region.
+	Labels []string
+}
+
+// WriteBinary writes the line noting that the named file, which has no
+// text diff, differs. The line is skipped by patch and git apply.
+func WriteBinary(w io.Writer, name string) error {
+	_, err := fmt.Fprintf(w, "Binary files %s and %s differ\n", quotePath("a/", name), quotePath("b/", name))
+	return err
+}
+
+// quotePath returns the name relative to the prefix. Like git, a path
+// containing quotes, backslashes or control characters is quoted, with C
+// escapes, so that both patch and git apply read it back.
+func quotePath(prefix, name string) string {
+	p := prefix + strings.TrimLeft(path.Clean(filepath.ToSlash(name)), "/")
+	if !strings.ContainsFunc(p, needsQuote) {
+		return p
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			if needsQuote(rune(c)) {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+func needsQuote(r rune) bool {
+	return r == '"' || r == '\\' || r < 0x20 || r == 0x7f
+}
+
+// headerPath returns the path of a --- or +++ header line. An unquoted
+// path containing a space is followed by a tab, which ends the path for
+// patch.
+func headerPath(prefix, name string) string {
+	p := quotePath(prefix, name)
+	if !strings.HasPrefix(p, `"`) && strings.Contains(p, " ") {
+		return p + "\t"
+	}
+	return p
+}
+
+// hunk maps a range of lines in the original file to a range of lines
+// in the modified file. Ranges are 0-based and end exclusive.
+type hunk struct {
+	a0, a1  int
+	b0, b1  int
+	labels  []string
+	changes []hunk
+}
+
+// Write writes the unified diff between the original content a and the
+// modified content b. Content outside of the regions must be identical.
+// The leading slash of an absolute name is removed from the paths of the
+// diff.
+func Write(w io.Writer, name string, a, b string, regions []Region) error {
+	x := lines(a)
+	y := lines(b)
+
+	changes, err := align(x, y, merge(regions, len(x)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	hunks := group(changes, len(x), len(y))
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", headerPath("a/", name), headerPath("b/", name))
+
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@", span(h.a0, h.a1), span(h.b0, h.b1))
+		if len(h.labels) > 0 {
+			fmt.Fprintf(&sb, " %s", strings.Join(h.labels, ", "))
+		}
+		sb.WriteString("\n")
+
+		i := h.a0
+		for _, c := range h.changes {
+			for ; i < c.a0; i++ {
+				line(&sb, ' ', x[i])
+			}
+			for ; i < c.a1; i++ {
+				line(&sb, '-', x[i])
+			}
+			for j := c.b0; j < c.b1; j++ {
+				line(&sb, '+', y[j])
+			}
+		}
+		for ; i < h.a1; i++ {
+			line(&sb, ' ', x[i])
+		}
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+func line(sb *strings.Builder, prefix byte, s string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(s)
+	if !strings.HasSuffix(s, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// span formats a range in a hunk header.
+func span(start, end int) string {
+	n := end - start
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// lines splits s into lines, retaining the newline.
+func lines(s string) []string {
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// merge converts the regions to 0-based ranges, combining overlapping
+// and adjacent regions.
+func merge(regions []Region, n int) []hunk {
+	r := slices.Clone(regions)
+	slices.SortFunc(r, func(a, b Region) int {
+		return a.Start - b.Start
+	})
+
+	m := make([]hunk, 0, len(r))
+
+	for _, v := range r {
+		a0 := max(v.Start-1, 0)
+		a1 := min(v.End, n)
+		if a1 <= a0 {
+			continue
+		}
+
+		if len(m) > 0 && a0 <= m[len(m)-1].a1 {
+			last := &m[len(m)-1]
+			last.a1 = max(last.a1, a1)
+			last.labels = label(last.labels, v.Labels...)
+			continue
+		}
+
+		m = append(m, hunk{a0: a0, a1: a1, labels: label(nil, v.Labels...)})
+	}
+
+	return m
+}
+
+func label(labels []string, s ...string) []string {
+	for _, v := range s {
+		if !slices.Contains(labels, v) {
+			labels = append(labels, v)
+		}
+	}
+	return labels
+}
+
+// align finds the lines in the modified content corresponding to each
+// changed region. The unchanged lines between regions are identical in
+// both files: the modified region ends where the following unchanged
+// lines begin.
+func align(x, y []string, regions []hunk) ([]hunk, error) {
+	changes := make([]hunk, 0, len(regions))
+
+	i, j := 0, 0
+	for n, r := range regions {
+		if !slices.Equal(x[i:r.a0], y[j:min(j+r.a0-i, len(y))]) {
+			return nil, ErrNotFound
+		}
+		j += r.a0 - i
+
+		next := len(x)
+		if n+1 < len(regions) {
+			next = regions[n+1].a0
+		}
+		unchanged := x[r.a1:next]
+
+		end := -1
+		if next == len(x) {
+			if k := len(y) - len(unchanged); k >= j && slices.Equal(y[k:], unchanged) {
+				end = k
+			}
+		} else {
+			for k := j; k+len(unchanged) <= len(y); k++ {
+				if slices.Equal(y[k:k+len(unchanged)], unchanged) {
+					end = k
+					break
+				}
+			}
+		}
+
+		if end < 0 {
+			return nil, ErrNotFound
+		}
+
+		r.b0, r.b1 = j, end
+		if !slices.Equal(x[r.a0:r.a1], y[r.b0:r.b1]) {
+			changes = append(changes, r)
+		}
+
+		i, j = r.a1, end
+	}
+
+	if !slices.Equal(x[i:], y[j:]) {
+		return nil, ErrNotFound
+	}
+
+	return changes, nil
+}
+
+// group combines changes separated by less than twice the context into
+// hunks.
+func group(changes []hunk, nx, ny int) []hunk {
+	hunks := make([]hunk, 0, len(changes))
+
+	for _, c := range changes {
+		a0 := max(c.a0-Context, 0)
+		b0 := c.b0 - (c.a0 - a0)
+		a1 := min(c.a1+Context, nx)
+		b1 := c.b1 + (a1 - c.a1)
+
+		if len(hunks) > 0 && a0 <= hunks[len(hunks)-1].a1 {
+			last := &hunks[len(hunks)-1]
+			last.a1, last.b1 = a1, min(b1, ny)
+			last.labels = label(last.labels, c.labels...)
+			last.changes = append(last.changes, c)
+			continue
+		}
+
+		hunks = append(hunks, hunk{
+			a0:      a0,
+			a1:      a1,
+			b0:      b0,
+			b1:      min(b1, ny),
+			labels:  label(nil, c.labels...),
+			changes: []hunk{c},
+		})
+	}
+
+	return hunks
+}
@@ -0,0 +1,86 @@
+package unidiff_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.iscode.ca/redact/internal/pkg/unidiff"
+)
+
+func TestWrite(t *testing.T) {
+	a := "1\n2\n3\n4\nsecret\n5\n6\n7\n8\n9\n10\n11\nmulti\nline\nsecret"
+	b := "1\n2\n3\n4\n**REDACTED**\n5\n6\n7\n8\n9\n10\n11\n**REDACTED**"
+
+	expected := `--- a/file
++++ b/file
+@@ -2,7 +2,7 @@ rule-1
+ 2
+ 3
+ 4
+-secret
++**REDACTED**
+ 5
+ 6
+ 7
+@@ -10,6 +10,4 @@ rule-2, rule-3
+ 9
+ 10
+ 11
+-multi
+-line
+-secret
+\ No newline at end of file
++**REDACTED**
+\ No newline at end of file
+`
+
+	var sb strings.Builder
+
+	err := unidiff.Write(&sb, "file", a, b, []unidiff.Region{
+		{Start: 13, End: 15, Labels: []string{"rule-2"}},
+		{Start: 5, End: 5, Labels: []string{"rule-1"}},
+		{Start: 15, End: 15, Labels: []string{"rule-3"}},
+	})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	if sb.String() != expected {
+		t.Fatalf("diff failed: out=%s expected=%s", sb.String(), expected)
+	}
+}
+
+func TestWrite_unchanged(t *testing.T) {
+	var sb strings.Builder
+
+	if err := unidiff.Write(&sb, "file", "1\n2\n", "1\n2\n", []unidiff.Region{{Start: 1, End: 1}}); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	if sb.Len() != 0 {
+		t.Fatalf("diff failed: out=%s", sb.String())
+	}
+}
+
+func TestWrite_path(t *testing.T) {
+	for name, expected := range map[string]string{
+		"file":          "--- a/file\n+++ b/file\n",
+		"./dir/file":    "--- a/dir/file\n+++ b/dir/file\n",
+		"/tmp/file":     "--- a/tmp/file\n+++ b/tmp/file\n",
+		"/dev/stdin":    "--- a/dev/stdin\n+++ b/dev/stdin\n",
+		"//tmp/../file": "--- a/file\n+++ b/file\n",
+		"has space.txt": "--- a/has space.txt\t\n+++ b/has space.txt\t\n",
+		`q"uote\x`:      `--- "a/q\"uote\\x"` + "\n" + `+++ "b/q\"uote\\x"` + "\n",
+		"tab\tx\x01":    `--- "a/tab\tx\001"` + "\n" + `+++ "b/tab\tx\001"` + "\n",
+	} {
+		var sb strings.Builder
+
+		if err := unidiff.Write(&sb, name, "secret\n", "**REDACTED**\n", []unidiff.Region{{Start: 1, End: 1}}); err != nil {
+			t.Fatalf("diff: %v", err)
+		}
+
+		if !strings.HasPrefix(sb.String(), expected) {
+			t.Errorf("%s: %q, expected prefix %q", name, sb.String(), expected)
+		}
+	}
+}
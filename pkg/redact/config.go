@@ -0,0 +1,159 @@
+package redact
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/zricethezav/gitleaks/v8/config"
+)
+
+// maxExtendDepth is the number of configurations which may be chained
+// using the [extend] section.
+const maxExtendDepth = 2
+
+var (
+	ErrExtend      = errors.New("unable to load config: extend.path and extend.useDefault are both set")
+	ErrRedactRule  = errors.New("unable to load config: redact.rules requires an id and a method")
+	ErrUnknownRule = errors.New("unable to load config: redact.rules references an unknown rule")
+)
+
+// rulesConfig is a gitleaks configuration including the settings in the
+// [redact] section.
+type rulesConfig struct {
+	config.Config
+
+	// methods maps a rule ID to a redaction method.
+	methods map[string]string
+
+	// paths are key path patterns selecting values to redact in
+	// structured documents.
+	paths []string
+}
+
+// redactSection is the [redact] section of the rules:
+//
+//	[redact]
+//	paths = ["$..password"]
+//
+//	[[redact.rules]]
+//	id = "crypt-password-hash"
+//	method = "mask:10"
+type redactSection struct {
+	Paths []string
+	Rules []struct {
+		ID     string
+		Method string
+	}
+}
+
+// readConfig parses gitleaks rules in TOML format.
+//
+// The gitleaks config package loads extended configurations using the
+// global viper instance and tracks the extension depth in a global
+// variable. Each configuration is read using a private viper instance
+// and extensions are handled here so configurations can be loaded
+// concurrently.
+func readConfig(s string, depth int) (rulesConfig, error) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(strings.NewReader(s)); err != nil {
+		return rulesConfig{}, err
+	}
+
+	var vc config.ViperConfig
+	if err := v.Unmarshal(&vc); err != nil {
+		return rulesConfig{}, err
+	}
+
+	var rs redactSection
+	if err := v.UnmarshalKey("redact", &rs); err != nil {
+		return rulesConfig{}, err
+	}
+
+	extend := vc.Extend
+	vc.Extend = config.Extend{}
+
+	c, err := vc.Translate()
+	if err != nil {
+		return rulesConfig{}, err
+	}
+
+	c.Extend = extend
+
+	cfg := rulesConfig{
+		Config:  c,
+		methods: make(map[string]string, len(rs.Rules)),
+		paths:   rs.Paths,
+	}
+
+	for _, r := range rs.Rules {
+		if r.ID == "" || r.Method == "" {
+			return rulesConfig{}, ErrRedactRule
+		}
+		cfg.methods[r.ID] = r.Method
+	}
+
+	if depth >= maxExtendDepth {
+		return cfg, nil
+	}
+
+	var base string
+
+	switch {
+	case extend.Path != "" && extend.UseDefault:
+		return rulesConfig{}, ErrExtend
+	case extend.UseDefault:
+		base = config.DefaultConfig
+	case extend.Path != "":
+		b, err := os.ReadFile(extend.Path)
+		if err != nil {
+			return rulesConfig{}, err
+		}
+		base = string(b)
+	default:
+		return cfg, nil
+	}
+
+	ext, err := readConfig(base, depth+1)
+	if err != nil {
+		return rulesConfig{}, err
+	}
+
+	extendConfig(&cfg, ext)
+
+	return cfg, nil
+}
+
+// extendConfig adds the rules, allowlists, redaction methods and key
+// paths of the extension to the configuration. Rules and methods in the
+// configuration take precedence.
+func extendConfig(cfg *rulesConfig, ext rulesConfig) {
+	cfg.paths = append(cfg.paths, ext.paths...)
+
+	for id, method := range ext.methods {
+		if _, ok := cfg.methods[id]; !ok {
+			cfg.methods[id] = method
+		}
+	}
+
+	for id, rule := range ext.Rules {
+		if _, ok := cfg.Rules[id]; ok {
+			continue
+		}
+
+		cfg.Rules[id] = rule
+		for _, k := range rule.Keywords {
+			cfg.Keywords = append(cfg.Keywords, strings.ToLower(k))
+		}
+		cfg.OrderedRules = append(cfg.OrderedRules, id)
+	}
+
+	cfg.Allowlist.Commits = append(cfg.Allowlist.Commits, ext.Allowlist.Commits...)
+	cfg.Allowlist.Paths = append(cfg.Allowlist.Paths, ext.Allowlist.Paths...)
+	cfg.Allowlist.Regexes = append(cfg.Allowlist.Regexes, ext.Allowlist.Regexes...)
+
+	sort.Strings(cfg.OrderedRules)
+}
@@ -0,0 +1,146 @@
+package redact
+
+import (
+	"strings"
+)
+
+// RedactDotenv removes secrets from the values of a dotenv file:
+//
+//	# comment
+//	export DB_USER=admin
+//	DB_PASSWORD="secret" # comment
+//
+// Values are redacted if the rules detect a secret or if the key matches
+// a pattern set by WithKeyPaths: the key path of a value is the variable
+// name, for example "$.DB_PASSWORD" or "$..*password*".
+//
+// Double quoted values may contain the escapes \n, \r, \t, \", \\ and \$.
+// Single quoted and backquoted values are literal. Quoted values may
+// span lines. Comments are redacted as text.
+func (o *Opt) RedactDotenv(s string) (string, error) {
+	s, _, err := o.RedactDotenvWithFindings(s)
+	return s, err
+}
+
+// RedactDotenvWithFindings removes secrets from a dotenv file, returning
+// the redacted file and a description of each secret.
+func (o *Opt) RedactDotenvWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	return o.redactFields(s, parseDotenv(s))
+}
+
+var dotenvEscapes = map[byte]string{
+	'n': "\n", 'r': "\r", 't': "\t", '"': `"`, '\\': `\`, '$': "$",
+}
+
+func parseDotenv(s string) []field {
+	fields := make([]field, 0)
+
+	for off := 0; off < len(s); {
+		end := lineEnd(s, off)
+		i := skipSpace(s, off, end)
+
+		if i == end || s[i] == '#' {
+			fields = append(fields, literal(s, i, end, nil))
+			off = end + 1
+			continue
+		}
+
+		if strings.HasPrefix(s[i:end], "export ") {
+			i = skipSpace(s, i+len("export "), end)
+		}
+
+		eq := strings.IndexByte(s[i:end], '=')
+		key := ""
+		if eq >= 0 {
+			key = strings.TrimSpace(s[i : i+eq])
+		}
+
+		if key == "" || strings.ContainsAny(key, " \t") {
+			fields = append(fields, literal(s, off, end, nil))
+			off = end + 1
+			continue
+		}
+
+		path := []pathElem{{name: key}}
+		j := skipSpace(s, i+eq+1, end)
+
+		if j < end {
+			switch q := s[j]; q {
+			case '"':
+				if f, next, ok := dotenvDoubleQuoted(s, j, path); ok {
+					fields = append(fields, f)
+					end = lineEnd(s, next)
+					fields = append(fields, literal(s, next, end, nil))
+					off = end + 1
+					continue
+				}
+			case '\'', '`':
+				if k := strings.IndexByte(s[j+1:], q); k >= 0 {
+					close := j + 1 + k
+					f := literal(s, j+1, close, path)
+					f.start, f.end = j, close+1
+					f.encode = func(v string) string {
+						if strings.IndexByte(v, q) >= 0 {
+							return dotenvQuote(v)
+						}
+						return string(q) + v + string(q)
+					}
+					fields = append(fields, f)
+
+					end = lineEnd(s, close)
+					fields = append(fields, literal(s, close+1, end, nil))
+					off = end + 1
+					continue
+				}
+			}
+		}
+
+		// Unquoted values end at an inline comment.
+		c := comment(s, j, end, "#")
+		f := literal(s, j, trimEnd(s, j, c), path)
+		f.encode = func(v string) string {
+			if v == "" || strings.ContainsAny(v, " \t\r\n#\"'`") {
+				return dotenvQuote(v)
+			}
+			return v
+		}
+		fields = append(fields, f, literal(s, c, end, nil))
+		off = end + 1
+	}
+
+	return fields
+}
+
+// dotenvDoubleQuoted decodes the double quoted value starting at offset
+// start, returning the offset following the closing quote.
+func dotenvDoubleQuoted(s string, start int, path []pathElem) (field, int, bool) {
+	var d decoder
+
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return d.field(path, start, i+1, i, dotenvQuote), i + 1, true
+		case '\\':
+			if i+1 < len(s) {
+				if v, ok := dotenvEscapes[s[i+1]]; ok {
+					d.write(i, v)
+					i++
+					continue
+				}
+			}
+		}
+		d.write(i, s[i:i+1])
+	}
+
+	return field{}, 0, false
+}
+
+// dotenvQuote encodes v as a double quoted value.
+func dotenvQuote(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(v) + `"`
+}
@@ -1,8 +1,11 @@
 package redact_test
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"go.iscode.ca/redact/pkg/redact"
 	"go.iscode.ca/redact/pkg/redact/overwrite"
@@ -28,7 +31,7 @@ id = "crypt-password-hash"
 description = "Detected a password hash"
 regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
 `),
-		redact.WithOverwrite(&overwrite.Mask{Char: byte('*')}),
+		redact.WithOverwrite(&overwrite.Mask{Char: "*"}),
 	)
 	redacted, err := red.Redact("root:$6$d468dc01f1cd655d$1c0a188389f4db6399265080815ac488ea65c3295a18d2d7da3ce5e8ef082362adeedec9b69.9704d4d188:18515:0:99999:7:::")
 	if err != nil {
@@ -44,7 +47,7 @@ id = "crypt-password-hash"
 description = "Detected a password hash"
 regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
 `),
-		redact.WithOverwrite(&overwrite.Mask{Char: byte('*'), Unmasked: 10}),
+		redact.WithOverwrite(&overwrite.Mask{Char: "*", Unmasked: 10}),
 	)
 	redacted, err := red.Redact("root:$6$d468dc01f1cd655d$1c0a188389f4db6399265080815ac488ea65c3295a18d2d7da3ce5e8ef082362adeedec9b69.9704d4d188:18515:0:99999:7:::")
 	if err != nil {
@@ -53,3 +56,55 @@ regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
 	fmt.Println(redacted)
 	// Output: root:$6$d468d*********************************************************************************************4d188:18515:0:99999:7:::
 }
+
+func ExampleOpt_RedactStream() {
+	red := redact.New(redact.WithRules(`[[rules]]
+id = "crypt-password-hash"
+description = "Detected a password hash"
+regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
+`))
+	r := strings.NewReader("root:$6$d468dc01f1cd655d$1c0a188389f4db6399265080815ac488ea65c3295a18d2d7da3ce5e8ef082362adeedec9b69.9704d4d188:18515:0:99999:7:::\n")
+	if err := red.RedactStream(context.Background(), r, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+	// Output: root:$6$**REDACTED**:18515:0:99999:7:::
+}
+
+func ExampleOpt_Redact_hmac() {
+	red := redact.New(redact.WithRules(`[[rules]]
+id = "crypt-password-hash"
+description = "Detected a password hash"
+regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
+`),
+		redact.WithOverwrite(&overwrite.HMAC{Key: []byte("secret key"), Size: 6}),
+	)
+	redacted, err := red.Redact("root:$6$d468dc01f1cd655d$1c0a188389f4db6399265080815ac488ea65c3295a18d2d7da3ce5e8ef082362adeedec9b69.9704d4d188:18515:0:99999:7:::")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(redacted)
+	// Output: root:$6$**REDACTED:hmac:95adea**:18515:0:99999:7:::
+}
+
+func ExampleOpt_Redact_template() {
+	tmpl, err := overwrite.NewTemplate("**REDACTED:{{.RuleID}}:{{.Index}}**")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	red := redact.New(redact.WithRules(`[[rules]]
+id = "crypt-password-hash"
+description = "Detected a password hash"
+regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
+`),
+		redact.WithOverwrite(tmpl),
+	)
+	redacted, err := red.Redact("root:$6$d468dc01f1cd655d$1c0a:18515:0:99999:7:::\nuser:$6$82a3e1fd2a5d$4b0b:18515:0:99999:7:::")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(redacted)
+	// Output:
+	// root:$6$**REDACTED:crypt-password-hash:1**:18515:0:99999:7:::
+	// user:$6$**REDACTED:crypt-password-hash:2**:18515:0:99999:7:::
+}
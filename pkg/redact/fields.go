@@ -0,0 +1,164 @@
+package redact
+
+import (
+	"strings"
+)
+
+// field is a value in a line-oriented configuration file, such as a
+// dotenv, INI or properties file.
+type field struct {
+	// path is the key of the value. Text outside of key-value pairs,
+	// such as comments, has no path.
+	path []pathElem
+
+	// start and end are the offsets of the value in the file,
+	// including any quotes.
+	start int
+	end   int
+
+	// value is the decoded value. offs is the offset in the file of
+	// each byte of the value, followed by the offset of the end of the
+	// value.
+	value string
+	offs  []int
+
+	// encode converts a redacted value to the syntax of the original
+	// value.
+	encode func(string) string
+}
+
+// literal returns a field for text from start to end of s which is not
+// encoded.
+func literal(s string, start, end int, path []pathElem) field {
+	offs := make([]int, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		offs = append(offs, i)
+	}
+
+	return field{
+		path:   path,
+		start:  start,
+		end:    end,
+		value:  s[start:end],
+		offs:   offs,
+		encode: func(v string) string { return v },
+	}
+}
+
+// decoder builds a field value, recording the offset of each byte.
+type decoder struct {
+	b    strings.Builder
+	offs []int
+}
+
+// write appends the decoded text v found at offset off of the file.
+func (d *decoder) write(off int, v string) {
+	d.b.WriteString(v)
+	for range len(v) {
+		d.offs = append(d.offs, off)
+	}
+}
+
+// field returns the field for the decoded value located from start to
+// end of the file.
+func (d *decoder) field(path []pathElem, start, end, valueEnd int, encode func(string) string) field {
+	return field{
+		path:   path,
+		start:  start,
+		end:    end,
+		value:  d.b.String(),
+		offs:   append(d.offs, valueEnd),
+		encode: encode,
+	}
+}
+
+// redactFields removes secrets from the values of a configuration file.
+// Values are redacted if the rules detect a secret or if their key
+// matches a pattern set by WithKeyPaths.
+func (o *Opt) redactFields(s string, fields []field) (string, []Finding, error) {
+	edits := make([]edit, 0)
+	findings := make([]finding, 0)
+
+	n := 0
+
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+
+		var v string
+		var fs []finding
+		var err error
+
+		if pattern, ok := o.matchPath(f.path); ok {
+			x := finding{start: f.offs[0], end: f.offs[len(f.value)]}
+			x.RuleID = KeyPathRuleID
+			x.Description = "Matched key path " + pattern
+			x.Secret = f.value
+
+			v, err = o.overwriteFinding(x, n+1)
+			fs = []finding{x}
+		} else {
+			fs = o.allow(s, 1, o.detectField(f.path, f.value), func(x finding) (int, int) {
+				return f.offs[x.start], f.offs[x.end]
+			})
+			if len(fs) == 0 {
+				continue
+			}
+
+			v, err = o.replace(f.value, fs, n)
+
+			for i := range fs {
+				fs[i].start, fs[i].end = f.offs[fs[i].start], f.offs[fs[i].end]
+			}
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		n += len(fs)
+
+		edits = append(edits, edit{start: f.start, end: f.end, text: f.encode(v)})
+		findings = append(findings, fs...)
+	}
+
+	c := newCursor()
+
+	return splice(s, edits), o.findingsAt(s, 0, &c, findings), nil
+}
+
+// lineEnd returns the offset of the end of the line starting at off,
+// excluding the newline.
+func lineEnd(s string, off int) int {
+	if i := strings.IndexByte(s[off:], '\n'); i >= 0 {
+		return off + i
+	}
+	return len(s)
+}
+
+// skipSpace returns the offset of the first character from off to end
+// which is not a space or tab.
+func skipSpace(s string, off, end int) int {
+	for off < end && (s[off] == ' ' || s[off] == '\t') {
+		off++
+	}
+	return off
+}
+
+// trimEnd returns the offset of the end of s[start:end] excluding
+// trailing whitespace.
+func trimEnd(s string, start, end int) int {
+	return start + len(strings.TrimRight(s[start:end], " \t\r"))
+}
+
+// comment returns the offset of an inline comment starting with one of
+// the characters in chars and preceded by whitespace, or end.
+func comment(s string, start, end int, chars string) int {
+	for i := start; i < end; i++ {
+		if strings.IndexByte(chars, s[i]) >= 0 && i > start && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return i
+		}
+	}
+	return end
+}
@@ -0,0 +1,159 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Finding describes a secret removed from the input. The secret itself
+// is not included.
+type Finding struct {
+	// RuleID is the gitleaks rule which matched the secret.
+	RuleID string `json:"rule_id"`
+
+	// Description is the gitleaks rule description.
+	Description string `json:"description"`
+
+	// Start and End are the byte offsets of the secret in the input.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// StartLine and StartColumn are the 1-based position of the first
+	// byte of the secret. EndLine and EndColumn are the position of
+	// the last byte of the secret.
+	StartLine   int `json:"start_line"`
+	StartColumn int `json:"start_column"`
+	EndLine     int `json:"end_line"`
+	EndColumn   int `json:"end_column"`
+
+	// StartColumnUTF16 and EndColumnUTF16 are the 1-based columns of
+	// the start of the secret and of the end of the secret (exclusive),
+	// counted in UTF-16 code units as in editors and SARIF.
+	StartColumnUTF16 int `json:"-"`
+	EndColumnUTF16   int `json:"-"`
+
+	// Fingerprint is the hex encoded HMAC-SHA256 of the secret, keyed by
+	// WithFingerprintKey or else by a random key: it is not a plain hash
+	// of the secret and only matches fingerprints computed with the same
+	// key.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// cursor tracks the line and column of an offset in the input.
+type cursor struct {
+	off  int
+	line int
+	col  int
+
+	// col16 is the column in UTF-16 code units.
+	col16 int
+}
+
+func newCursor() cursor {
+	return cursor{line: 1, col: 1, col16: 1}
+}
+
+// advance moves the cursor forward to offset off in s. s is the input
+// starting at the cursor's initial offset.
+func (c *cursor) advance(s string, base, off int) {
+	for ; c.off < off; c.off++ {
+		b := s[c.off-base]
+		if b == '\n' {
+			c.line++
+			c.col = 1
+			c.col16 = 1
+			continue
+		}
+		c.col++
+		c.col16 += utf16Units(b)
+	}
+}
+
+// utf16Units returns the number of UTF-16 code units of the character
+// starting with the byte b. Continuation bytes have no units.
+func utf16Units(b byte) int {
+	switch {
+	case b&0xc0 == 0x80:
+		return 0
+	case b >= 0xf0:
+		return 2
+	}
+	return 1
+}
+
+// findingsAt converts the internal representation of findings to the
+// exported type. s is the input beginning at offset base and positioned
+// at c.
+func (o *Opt) findingsAt(s string, base int, c *cursor, v []finding) []Finding {
+	f := make([]Finding, 0, len(v))
+
+	for _, x := range v {
+		c.advance(s, base, base+x.start)
+		line, col, col16 := c.line, c.col, c.col16
+		c.advance(s, base, base+x.end-1)
+
+		// The end column is the start column if the secret is on one
+		// line, otherwise the start of the last line.
+		secret := s[x.start:x.end]
+		end16 := col16
+		if n := strings.LastIndexByte(secret, '\n'); n >= 0 {
+			end16 = 1
+			secret = secret[n+1:]
+		}
+		for i := 0; i < len(secret); i++ {
+			end16 += utf16Units(secret[i])
+		}
+
+		f = append(f, Finding{
+			RuleID:      x.RuleID,
+			Description: x.Description,
+			Start:       base + x.start,
+			End:         base + x.end,
+			StartLine:   line,
+			StartColumn: col,
+			EndLine:     c.line,
+			EndColumn:   c.col,
+
+			StartColumnUTF16: col16,
+			EndColumnUTF16:   end16,
+			Fingerprint:      o.fingerprint(x.Secret),
+		})
+	}
+
+	return f
+}
+
+// WithFingerprintKey sets the key of the digests identifying secrets in
+// findings. Without a key, a random key is generated: fingerprints of
+// the same secret only match within an Opt.
+func WithFingerprintKey(key []byte) Option {
+	return func(o *Opt) {
+		if len(key) > 0 {
+			// Derive a key distinct from the key of the hmac method.
+			m := hmac.New(sha256.New, key)
+			m.Write([]byte("go.iscode.ca/redact fingerprint"))
+			o.fingerprintKey = m.Sum(nil)
+		}
+	}
+}
+
+// randomKey returns a key for fingerprints if no key is set.
+func randomKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// fingerprint returns the hex encoded HMAC-SHA256 of the secret. The
+// digest is keyed: a secret with low entropy can't be recovered from
+// the fingerprint by hashing guesses.
+func (o *Opt) fingerprint(s string) string {
+	m := hmac.New(sha256.New, o.fingerprintKey)
+	m.Write([]byte(s))
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package redact
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// allowComment marks a line containing a secret which is not redacted.
+const allowComment = "gitleaks:allow"
+
+// WithIgnoreFingerprints sets the gitleaks fingerprints of secrets which
+// are not redacted, as listed in a .gitleaksignore file. A fingerprint
+// is the path of a file, the rule ID and the line of the secret:
+//
+//	config/app.yml:generic-api-key:12
+//
+// The line may be replaced by the Finding.Fingerprint of the secret,
+// which is stable if the fingerprint key is set, or omitted to ignore the
+// secrets detected by the rule anywhere in the file.
+//
+// Relative paths in the fingerprints are relative to dir, usually the
+// directory containing the .gitleaksignore file. Fingerprints apply to
+// the file set by ForFile.
+func WithIgnoreFingerprints(dir string, fingerprints ...string) Option {
+	return func(o *Opt) {
+		for _, v := range fingerprints {
+			if k, ok := parseFingerprint(dir, v); ok {
+				o.ignore[k] = true
+			}
+		}
+	}
+}
+
+// ReadIgnoreFingerprints reads the fingerprints of a .gitleaksignore
+// file. Blank lines and comments starting with '#' are skipped.
+func ReadIgnoreFingerprints(r io.Reader) ([]string, error) {
+	v := make([]string, 0)
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		v = append(v, s)
+	}
+
+	return v, sc.Err()
+}
+
+// ForFile returns a copy of the configuration for redacting the named
+// file: secrets matching the fingerprints for the file are not
+// redacted. A relative name is compared to the path in the fingerprints
+// joined to their directory.
+func (o *Opt) ForFile(name string) *Opt {
+	c := *o
+	c.file = cleanPath(name)
+	return &c
+}
+
+func cleanPath(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// parseFingerprint returns the path joined to dir, rule ID and optional
+// line or digest of a fingerprint, joined by ':'. A fingerprint prefixed
+// by a commit never matches a file.
+func parseFingerprint(dir, s string) (string, bool) {
+	rest, last, ok := cut(s)
+	if !ok {
+		return "", false
+	}
+
+	if !isLine(last) && !isDigest(last) {
+		if rest == "" {
+			return "", false
+		}
+		return joinPath(dir, rest) + ":" + last, true
+	}
+
+	name, rule, ok := cut(rest)
+	if !ok || name == "" {
+		return "", false
+	}
+
+	return joinPath(dir, name) + ":" + rule + ":" + last, true
+}
+
+// joinPath returns the cleaned path of name relative to dir.
+func joinPath(dir, name string) string {
+	if dir == "" || filepath.IsAbs(name) || path.IsAbs(name) {
+		return cleanPath(name)
+	}
+	return cleanPath(filepath.Join(dir, name))
+}
+
+// cut splits s at the last ':'.
+func cut(s string) (string, string, bool) {
+	n := strings.LastIndexByte(s, ':')
+	if n < 0 || n == len(s)-1 {
+		return "", "", false
+	}
+	return s[:n], s[n+1:], true
+}
+
+func isLine(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
+
+func isDigest(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ignored reports whether the secret detected by the rule at the line
+// of the file matches a fingerprint.
+func (o *Opt) ignored(v finding, line int) bool {
+	if len(o.ignore) == 0 {
+		return false
+	}
+
+	k := o.file + ":" + v.RuleID
+	return o.ignore[k] || o.ignore[k+":"+strconv.Itoa(line)] || o.ignore[k+":"+o.fingerprint(v.Secret)]
+}
+
+// allow removes the findings which are not redacted: the lines of doc
+// containing the secret have a gitleaks:allow comment or the secret
+// matches a fingerprint. pos returns the offsets in doc of a finding.
+// line is the line number of the start of doc.
+func (o *Opt) allow(doc string, line int, findings []finding, pos func(finding) (int, int)) []finding {
+	v := findings[:0]
+
+	for _, f := range findings {
+		start, end := pos(f)
+
+		first := strings.LastIndexByte(doc[:start], '\n') + 1
+		last := len(doc)
+		if n := strings.IndexByte(doc[end:], '\n'); n >= 0 {
+			last = end + n
+		}
+
+		if strings.Contains(doc[first:last], allowComment) {
+			continue
+		}
+
+		if o.ignored(f, line+strings.Count(doc[:start], "\n")) {
+			continue
+		}
+
+		v = append(v, f)
+	}
+
+	return v
+}
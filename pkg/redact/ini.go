@@ -0,0 +1,155 @@
+package redact
+
+import (
+	"strings"
+)
+
+// RedactINI removes secrets from the values of an INI file:
+//
+//	; comment
+//	[database]
+//	user = admin
+//	password = "secret" ; comment
+//
+// Values are redacted if the rules detect a secret or if the key matches
+// a pattern set by WithKeyPaths: the key path of a value is the section
+// and the key, for example "$.database.password" or "$..password". Keys
+// before the first section have no section.
+//
+// Double quoted values may contain the escapes \n, \t, \", \\ and a
+// newline. Unquoted values end at a comment preceded by whitespace and
+// continue on the next line if the line ends with a backslash. Comments
+// are redacted as text.
+func (o *Opt) RedactINI(s string) (string, error) {
+	s, _, err := o.RedactINIWithFindings(s)
+	return s, err
+}
+
+// RedactINIWithFindings removes secrets from an INI file, returning the
+// redacted file and a description of each secret.
+func (o *Opt) RedactINIWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	return o.redactFields(s, parseINI(s))
+}
+
+var iniEscapes = map[byte]string{
+	'n': "\n", 't': "\t", '"': `"`, '\\': `\`, '\n': "",
+}
+
+func parseINI(s string) []field {
+	fields := make([]field, 0)
+
+	var section []pathElem
+
+	for off := 0; off < len(s); {
+		end := lineEnd(s, off)
+		i := skipSpace(s, off, end)
+
+		if i == end || s[i] == ';' || s[i] == '#' {
+			fields = append(fields, literal(s, i, end, nil))
+			off = end + 1
+			continue
+		}
+
+		if s[i] == '[' {
+			if k := strings.IndexByte(s[i:end], ']'); k >= 0 {
+				section = []pathElem{{name: strings.TrimSpace(s[i+1 : i+k])}}
+				fields = append(fields, literal(s, i+k+1, end, nil))
+				off = end + 1
+				continue
+			}
+		}
+
+		eq := strings.IndexAny(s[i:end], "=:")
+		if eq <= 0 {
+			fields = append(fields, literal(s, off, end, nil))
+			off = end + 1
+			continue
+		}
+
+		path := append(section[:len(section):len(section)], pathElem{name: strings.TrimSpace(s[i : i+eq])})
+		j := skipSpace(s, i+eq+1, end)
+
+		if j < end && s[j] == '"' {
+			if f, next, ok := iniDoubleQuoted(s, j, path); ok {
+				fields = append(fields, f)
+				end = lineEnd(s, next)
+				fields = append(fields, literal(s, next, end, nil))
+				off = end + 1
+				continue
+			}
+		}
+
+		// Unquoted values end at an inline comment and continue on the
+		// next line following a backslash.
+		var d decoder
+		k := j
+		for {
+			c := comment(s, k, end, ";#")
+			if c == end && end < len(s) && strings.HasSuffix(strings.TrimRight(s[k:end], "\r"), `\`) {
+				bs := strings.LastIndexByte(s[k:end], '\\') + k
+				for x := k; x < bs; x++ {
+					d.write(x, s[x:x+1])
+				}
+				k = end + 1
+				end = lineEnd(s, k)
+				continue
+			}
+
+			e := trimEnd(s, k, c)
+			for x := k; x < e; x++ {
+				d.write(x, s[x:x+1])
+			}
+
+			fields = append(fields, d.field(path, j, e, e, iniQuote), literal(s, c, end, nil))
+			break
+		}
+
+		off = end + 1
+	}
+
+	return fields
+}
+
+// iniDoubleQuoted decodes the double quoted value starting at offset
+// start, returning the offset following the closing quote.
+func iniDoubleQuoted(s string, start int, path []pathElem) (field, int, bool) {
+	var d decoder
+
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return d.field(path, start, i+1, i, iniDoubleQuote), i + 1, true
+		case '\n':
+			return field{}, 0, false
+		case '\\':
+			if i+1 < len(s) {
+				if v, ok := iniEscapes[s[i+1]]; ok {
+					d.write(i, v)
+					i++
+					continue
+				}
+			}
+		}
+		d.write(i, s[i:i+1])
+	}
+
+	return field{}, 0, false
+}
+
+// iniQuote encodes v as an unquoted value if possible.
+func iniQuote(v string) string {
+	if v == "" || strings.TrimSpace(v) != v || strings.ContainsAny(v, ";#\"\\\n\r") {
+		return iniDoubleQuote(v)
+	}
+	return v
+}
+
+// iniDoubleQuote encodes v as a double quoted value.
+func iniDoubleQuote(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(v) + `"`
+}
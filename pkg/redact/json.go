@@ -0,0 +1,307 @@
+package redact
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrInvalidJSON = errors.New("invalid JSON document")
+
+// jsonEscapes maps the character following a backslash in a JSON string
+// to the escaped character.
+var jsonEscapes = map[byte]byte{
+	'"': '"', '\\': '\\', '/': '/',
+	'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t',
+}
+
+// RedactJSON removes secrets from the string values of a JSON document.
+// Values are redacted if the rules detect a secret or if their key path
+// matches a pattern set by WithKeyPaths.
+//
+// The output is a valid JSON document. Only the redacted values are
+// modified: key order, whitespace and the escaping of other values are
+// preserved.
+func (o *Opt) RedactJSON(s string) (string, error) {
+	s, _, err := o.RedactJSONWithFindings(s)
+	return s, err
+}
+
+// RedactJSONWithFindings removes secrets from a JSON document, returning
+// the redacted document and a description of each secret. Offsets in the
+// findings refer to the original document.
+func (o *Opt) RedactJSONWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	if !json.Valid([]byte(s)) {
+		return "", nil, ErrInvalidJSON
+	}
+
+	p := &jsonParser{o: o, s: s}
+	p.value(false)
+
+	if p.err != nil {
+		return "", nil, p.err
+	}
+
+	c := newCursor()
+
+	return splice(s, p.edits), o.findingsAt(s, 0, &c, p.findings), nil
+}
+
+// edit replaces the bytes from start to end of the input.
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// splice applies the edits to s. The edits must be ordered by offset and
+// must not overlap.
+func splice(s string, edits []edit) string {
+	if len(edits) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+
+	off := 0
+	for _, v := range edits {
+		b.WriteString(s[off:v.start])
+		b.WriteString(v.text)
+		off = v.end
+	}
+	b.WriteString(s[off:])
+
+	return b.String()
+}
+
+// jsonParser walks a valid JSON document, recording the edits removing
+// secrets.
+type jsonParser struct {
+	o *Opt
+	s string
+	i int
+
+	path     []pathElem
+	edits    []edit
+	findings []finding
+
+	// err is the first error replacing a secret.
+	err error
+}
+
+// value parses the value at the current offset. matched is set if the
+// path to the value matches a key path pattern.
+func (p *jsonParser) value(matched bool) {
+	p.space()
+
+	switch p.s[p.i] {
+	case '{':
+		p.i++
+		for {
+			p.space()
+			if p.s[p.i] == '}' {
+				p.i++
+				return
+			}
+			if p.s[p.i] == ',' {
+				p.i++
+				p.space()
+			}
+
+			start := p.i
+			p.skipString()
+			name, _ := unquoteJSON(p.s[start:p.i], start)
+
+			p.space()
+			p.i++ // :
+
+			p.path = append(p.path, pathElem{name: name})
+			p.value(matched || p.match())
+			p.path = p.path[:len(p.path)-1]
+		}
+	case '[':
+		p.i++
+		for n := 0; ; n++ {
+			p.space()
+			if p.s[p.i] == ']' {
+				p.i++
+				return
+			}
+			if p.s[p.i] == ',' {
+				p.i++
+			}
+
+			p.path = append(p.path, pathElem{name: strconv.Itoa(n), index: true})
+			p.value(matched || p.match())
+			p.path = p.path[:len(p.path)-1]
+		}
+	case '"':
+		start := p.i
+		p.skipString()
+		p.redactString(start, p.i, matched)
+	default:
+		start := p.i
+		for p.i < len(p.s) && !strings.ContainsRune(",]} \t\r\n", rune(p.s[p.i])) {
+			p.i++
+		}
+		if matched && p.s[start:p.i] != "null" {
+			p.redactValue(start, p.i, p.s[start:p.i], start, p.i)
+		}
+	}
+}
+
+func (p *jsonParser) match() bool {
+	_, ok := p.o.matchPath(p.path)
+	return ok
+}
+
+// redactString removes secrets from the string literal between start and
+// end.
+func (p *jsonParser) redactString(start, end int, matched bool) {
+	v, offs := unquoteJSON(p.s[start:end], start)
+
+	if matched {
+		if v != "" {
+			p.redactValue(start, end, v, start+1, end-1)
+		}
+		return
+	}
+
+	findings := p.o.allow(p.s, 1, p.o.detectField(p.path, v), func(f finding) (int, int) {
+		return offs[f.start], offs[f.end]
+	})
+	if len(findings) == 0 {
+		return
+	}
+
+	text, err := p.o.replace(v, findings, len(p.findings))
+	if err != nil {
+		p.err = cmp.Or(p.err, err)
+		return
+	}
+
+	p.edits = append(p.edits, edit{
+		start: start,
+		end:   end,
+		text:  quoteJSON(text),
+	})
+
+	for _, f := range findings {
+		f.start, f.end = offs[f.start], offs[f.end]
+		p.findings = append(p.findings, f)
+	}
+}
+
+// redactValue replaces the literal between start and end with the
+// redacted secret. The secret is located between secretStart and
+// secretEnd of the input.
+func (p *jsonParser) redactValue(start, end int, secret string, secretStart, secretEnd int) {
+	pattern, _ := p.o.matchPath(p.path)
+
+	f := finding{start: secretStart, end: secretEnd}
+	f.RuleID = KeyPathRuleID
+	f.Description = "Matched key path " + pattern
+	f.Secret = secret
+
+	text, err := p.o.overwriteFinding(f, len(p.findings)+1)
+	if err != nil {
+		p.err = cmp.Or(p.err, err)
+		return
+	}
+
+	p.edits = append(p.edits, edit{
+		start: start,
+		end:   end,
+		text:  quoteJSON(text),
+	})
+	p.findings = append(p.findings, f)
+}
+
+func (p *jsonParser) space() {
+	for p.i < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+func (p *jsonParser) skipString() {
+	for p.i++; p.s[p.i] != '"'; p.i++ {
+		if p.s[p.i] == '\\' {
+			p.i++
+		}
+	}
+	p.i++
+}
+
+// unquoteJSON decodes a JSON string literal located at offset base of the
+// input. The offset in the input of each byte of the decoded string is
+// returned, followed by the offset of the closing quote.
+func unquoteJSON(s string, base int) (string, []int) {
+	var b strings.Builder
+	offs := make([]int, 0, len(s))
+
+	for i := 1; i < len(s)-1; {
+		start := i
+
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			offs = append(offs, base+i)
+			i++
+			continue
+		}
+
+		var r rune
+
+		switch s[i+1] {
+		case 'u':
+			r = hex4(s[i+2 : i+6])
+			i += 6
+			if utf16.IsSurrogate(r) {
+				r2 := utf8.RuneError
+				if i+6 <= len(s)-1 && s[i] == '\\' && s[i+1] == 'u' {
+					r2 = hex4(s[i+2 : i+6])
+				}
+				if d := utf16.DecodeRune(r, r2); d != utf8.RuneError {
+					r = d
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+		default:
+			r = rune(jsonEscapes[s[i+1]])
+			i += 2
+		}
+
+		n, _ := b.WriteRune(r)
+		for ; n > 0; n-- {
+			offs = append(offs, base+start)
+		}
+	}
+
+	return b.String(), append(offs, base+len(s)-1)
+}
+
+func hex4(s string) rune {
+	n, _ := strconv.ParseUint(s, 16, 16)
+	return rune(n)
+}
+
+// quoteJSON encodes s as a JSON string literal.
+func quoteJSON(s string) string {
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,187 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// KeyPathRuleID is the rule ID of values redacted because their key path
+// matches a pattern set by WithKeyPaths.
+const KeyPathRuleID = "key-path"
+
+var ErrKeyPath = errors.New("invalid key path")
+
+// WithKeyPaths sets patterns selecting values to redact in structured
+// documents, in addition to the secrets detected by the rules. A pattern
+// is a subset of JSONPath:
+//
+//	$          the document root
+//	.name      a key matching the glob name
+//	..name     a key at any depth matching the glob name
+//	['name']   a key containing dots or brackets
+//	[n], [*]   an array element
+//
+// Keys are matched case-insensitively using path.Match. For example,
+// "$..password" matches any key named password and "$.*.client_secret"
+// matches client_secret in any top level object. All values under a
+// matching key are redacted.
+//
+// Key paths may also be set in the [redact] section of the rules:
+//
+//	[redact]
+//	paths = ["$..password"]
+func WithKeyPaths(patterns ...string) Option {
+	return func(o *Opt) {
+		o.patterns = append(o.patterns, patterns...)
+	}
+}
+
+// keyPath is a parsed key path pattern.
+type keyPath struct {
+	pattern string
+	segs    []segment
+}
+
+// segment is a component of a key path pattern.
+type segment struct {
+	// descendant matches the segment at any depth.
+	descendant bool
+
+	// index matches array elements.
+	index bool
+
+	// glob is the lowercase pattern for the key or index. "*" matches
+	// any key or index.
+	glob string
+}
+
+// pathElem is a component of the path to a value in a document.
+type pathElem struct {
+	name  string
+	index bool
+}
+
+func parseKeyPath(pattern string) (*keyPath, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%s: %s: %w", pattern, reason, ErrKeyPath)
+	}
+
+	s, ok := strings.CutPrefix(pattern, "$")
+	if !ok {
+		return nil, invalid("must start with $")
+	}
+
+	k := &keyPath{pattern: pattern}
+
+	for s != "" {
+		var seg segment
+
+		switch {
+		case strings.HasPrefix(s, ".."):
+			seg.descendant = true
+			s = s[2:]
+		case strings.HasPrefix(s, "."):
+			s = s[1:]
+		case strings.HasPrefix(s, "["):
+		default:
+			return nil, invalid("expected . or [")
+		}
+
+		if strings.HasPrefix(s, "[") {
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, invalid("missing ]")
+			}
+
+			v := s[1:end]
+			s = s[end+1:]
+
+			switch {
+			case v == "*":
+				seg.glob = v
+			case len(v) >= 2 && (v[0] == '\'' || v[0] == '"') && v[len(v)-1] == v[0]:
+				seg.glob = v[1 : len(v)-1]
+			default:
+				if _, err := strconv.Atoi(v); err != nil {
+					return nil, invalid("invalid array index")
+				}
+				seg.glob = v
+				seg.index = true
+			}
+		} else {
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			seg.glob = s[:end]
+			s = s[end:]
+		}
+
+		if seg.glob == "" {
+			return nil, invalid("empty key")
+		}
+
+		seg.glob = strings.ToLower(seg.glob)
+		if _, err := path.Match(seg.glob, ""); err != nil {
+			return nil, invalid(err.Error())
+		}
+
+		k.segs = append(k.segs, seg)
+	}
+
+	if len(k.segs) == 0 {
+		return nil, invalid("matches the document root")
+	}
+
+	return k, nil
+}
+
+// match reports whether the path to a value matches the pattern.
+func (k *keyPath) match(p []pathElem) bool {
+	return matchSegments(k.segs, p)
+}
+
+func matchSegments(segs []segment, p []pathElem) bool {
+	if len(segs) == 0 {
+		return len(p) == 0
+	}
+
+	s := segs[0]
+
+	if s.descendant {
+		for i := range p {
+			if s.match(p[i]) && matchSegments(segs[1:], p[i+1:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return len(p) > 0 && s.match(p[0]) && matchSegments(segs[1:], p[1:])
+}
+
+func (s segment) match(e pathElem) bool {
+	if s.glob == "*" {
+		return true
+	}
+
+	if s.index != e.index {
+		return false
+	}
+
+	ok, _ := path.Match(s.glob, strings.ToLower(e.name))
+	return ok
+}
+
+// matchPath returns the pattern matching the path to a value.
+func (o *Opt) matchPath(p []pathElem) (string, bool) {
+	for _, k := range o.paths {
+		if k.match(p) {
+			return k.pattern, true
+		}
+	}
+	return "", false
+}
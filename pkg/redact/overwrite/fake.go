@@ -0,0 +1,97 @@
+package overwrite
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	mrand "math/rand/v2"
+	"strings"
+	"unicode"
+)
+
+const (
+	digits = "0123456789"
+	lower  = "abcdefghijklmnopqrstuvwxyz"
+	upper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// Fake replaces a secret with a generated value of the same length and
+// character classes, so the value passes format checks of the systems
+// consuming it:
+//
+//   - digits are replaced with digits
+//   - letters are replaced with letters of the same case. If the only
+//     letters in the secret are a-f and A-F, the secret is assumed to
+//     be hex encoded and the letters are replaced with hex digits.
+//   - other characters, such as separators and base64 padding, are kept
+//
+// Non-ASCII letters and digits are replaced with ASCII characters.
+type Fake struct {
+	// Key makes the replacement deterministic: the same secret and key
+	// always produce the same value. If Key is not set, a random value
+	// is generated for each secret.
+	Key []byte
+}
+
+func (f *Fake) Replace(s string) string {
+	lo, up := lower, upper
+	if isHex(s) {
+		lo, up = lower[:6], upper[:6]
+	}
+
+	r := f.rand(s)
+
+	for {
+		var b strings.Builder
+		b.Grow(len(s))
+
+		for _, c := range s {
+			switch {
+			case c <= unicode.MaxASCII && unicode.IsDigit(c):
+				b.WriteByte(digits[r.IntN(len(digits))])
+			case unicode.IsUpper(c):
+				b.WriteByte(up[r.IntN(len(up))])
+			case unicode.IsLetter(c):
+				b.WriteByte(lo[r.IntN(len(lo))])
+			case unicode.IsDigit(c):
+				b.WriteByte(digits[r.IntN(len(digits))])
+			default:
+				b.WriteRune(c)
+			}
+		}
+
+		// Short secrets may be replaced by themselves: draw again
+		// unless the secret has no replaceable characters.
+		if v := b.String(); v != s || !strings.ContainsFunc(s, replaceable) {
+			return v
+		}
+	}
+}
+
+// rand returns the source of replacement characters for the secret.
+func (f *Fake) rand(s string) *mrand.Rand {
+	var seed [32]byte
+
+	if len(f.Key) == 0 {
+		if _, err := rand.Read(seed[:]); err != nil {
+			panic(err)
+		}
+	} else {
+		m := hmac.New(sha256.New, f.Key)
+		m.Write([]byte(s))
+		copy(seed[:], m.Sum(nil))
+	}
+
+	return mrand.New(mrand.NewChaCha8(seed))
+}
+
+func replaceable(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// isHex reports whether the letters in s are all hex digits.
+func isHex(s string) bool {
+	return !strings.ContainsFunc(s, func(c rune) bool {
+		return unicode.IsLetter(c) && !strings.ContainsRune("abcdefABCDEF", c)
+	})
+}
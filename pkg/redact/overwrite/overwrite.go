@@ -2,19 +2,71 @@
 package overwrite
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"text/template"
+
+	"github.com/rivo/uniseg"
+)
+
+var (
+	ErrMethod = errors.New("invalid redaction method")
+	ErrKey    = errors.New("redaction method requires a key")
 )
 
 type Replacer interface {
 	Replace(string) string
 }
 
+// Match describes a secret detected in the input.
+type Match struct {
+	Secret string
+
+	// RuleID and Description identify the gitleaks rule which matched
+	// the secret.
+	RuleID      string
+	Description string
+
+	// Index is the 1-based position of the secret among the secrets
+	// in the input.
+	Index int
+
+	// Fingerprint is the keyed digest of the secret identifying the
+	// secret in reports.
+	Fingerprint string
+}
+
+// Length is the number of characters (grapheme clusters) in the secret.
+func (m Match) Length() int {
+	return uniseg.GraphemeClusterCount(m.Secret)
+}
+
+// MatchReplacer is implemented by replacers using the context of the
+// secret. The redact package calls ReplaceMatch in preference to
+// Replace.
+type MatchReplacer interface {
+	Replacer
+	ReplaceMatch(Match) (string, error)
+}
+
 type Redact struct {
 	Text string
 }
 
+// Mask overwrites each character of the secret. A character is a
+// user-perceived character (grapheme cluster) rather than a byte.
 type Mask struct {
-	Char     byte
+	// Char replaces each masked character (default "*").
+	Char string
+
+	// Unmasked is the percentage of characters of the secret left
+	// visible, split between the start and the end of the secret.
 	Unmasked int
 }
 
@@ -23,17 +75,207 @@ func (r *Redact) Replace(_ string) string {
 }
 
 func (m *Mask) Replace(s string) string {
-	l := len(s)
+	char := m.Char
+	if char == "" {
+		char = "*"
+	}
+
+	g := graphemes(s)
+	l := len(g) - 1
 
 	if m.Unmasked <= 0 {
-		return strings.Repeat(string(m.Char), l)
+		return strings.Repeat(char, l)
 	} else if m.Unmasked >= 100 {
 		return s
 	}
 
 	unmasked := l * m.Unmasked / 100
 	masked := l - unmasked
-	unmasked /= 2 // unmasked prefix and suffix bytes
+	unmasked /= 2 // unmasked prefix and suffix characters
+
+	return s[:g[unmasked]] + strings.Repeat(char, masked) + s[g[unmasked+masked]:]
+}
+
+// graphemes returns the byte offset of each character in s, followed by
+// len(s).
+func graphemes(s string) []int {
+	off := make([]int, 0, len(s)+1)
+
+	state := -1
+	for n := 0; n < len(s); {
+		off = append(off, n)
+
+		var c string
+		c, _, _, state = uniseg.StepString(s[n:], state)
+		n += len(c)
+	}
+
+	return append(off, len(s))
+}
+
+// Template replaces a secret with text generated from a template. The
+// template is executed with the context of the secret:
+//
+//	**REDACTED:{{.RuleID}}:{{.Length}}**
+//
+// The RuleID, Description, Index, Length and Fingerprint of the Match
+// may be used. The secret is not available to templates.
+type Template struct {
+	t *template.Template
+}
+
+// NewTemplate parses the template text.
+func NewTemplate(text string) (*Template, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &Template{t: t}
+
+	// Report unknown fields when the template is parsed rather than
+	// when a secret is replaced.
+	sample := Match{
+		Secret:      "secret",
+		RuleID:      "rule",
+		Description: "description",
+		Index:       1,
+		Fingerprint: strings.Repeat("0", 64),
+	}
+	if err := t.Execute(io.Discard, newTemplateData(sample)); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Replace replaces a secret without context: only the Length is set,
+// while RuleID, Description and Fingerprint are empty and Index is 0. If
+// the template fails, the secret is replaced by an empty string.
+func (t *Template) Replace(s string) string {
+	v, err := t.ReplaceMatch(Match{Secret: s})
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+func (t *Template) ReplaceMatch(m Match) (string, error) {
+	var b strings.Builder
+
+	if err := t.t.Execute(&b, newTemplateData(m)); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// templateData is the context of a secret passed to templates. The
+// secret is not included.
+type templateData struct {
+	RuleID      string
+	Description string
+	Index       int
+	Length      int
+	Fingerprint string
+}
+
+func newTemplateData(m Match) templateData {
+	return templateData{
+		RuleID:      m.RuleID,
+		Description: m.Description,
+		Index:       m.Index,
+		Length:      m.Length(),
+		Fingerprint: m.Fingerprint,
+	}
+}
+
+// DefaultHMACSize is the default number of hex digits in an HMAC token.
+const DefaultHMACSize = 12
+
+// HMAC replaces a secret with a token derived from a keyed hash of the
+// secret: the same secret and key always produce the same token.
+type HMAC struct {
+	Key []byte
+
+	// Size is the number of hex digits of the digest included in the
+	// token (default 12).
+	Size int
+}
+
+func (h *HMAC) Replace(s string) string {
+	m := hmac.New(sha256.New, h.Key)
+	m.Write([]byte(s))
+	sum := hex.EncodeToString(m.Sum(nil))
+
+	n := h.Size
+	if n <= 0 {
+		n = DefaultHMACSize
+	}
+	n = min(n, len(sum))
+
+	return "**REDACTED:hmac:" + sum[:n] + "**"
+}
+
+// Parse returns the Replacer for a redaction method:
+//
+//   - redact: substitute the secret with text. Text containing "{{" is
+//     a Template.
+//   - mask, mask:<percentage>: set each character of the secret to the
+//     first character of text (default "*"), optionally leaving a percentage of the
+//     secret unmasked
+//   - fake: substitute the secret with a value of the same format,
+//     derived from key if set
+//   - hmac, hmac:<digits>: substitute the secret with a token derived
+//     from key
+func Parse(method, text string, key []byte) (Replacer, error) {
+	name, arg, ok := strings.Cut(method, ":")
+
+	n := 0
+	if ok {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, ErrMethod)
+		}
+		n = v
+	}
+
+	switch name {
+	case "redact":
+		if ok {
+			return nil, fmt.Errorf("%s: %w", method, ErrMethod)
+		}
+
+		if !strings.Contains(text, "{{") {
+			return &Redact{Text: text}, nil
+		}
+
+		t, err := NewTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", text, err)
+		}
+		return t, nil
+	case "mask":
+		if n < 0 || n > 100 {
+			return nil, fmt.Errorf("%s: unmasked value must be a percentage in range 0-100: %w", method, ErrMethod)
+		}
+
+		char, _, _, _ := uniseg.FirstGraphemeClusterInString(text, -1)
+		return &Mask{Char: char, Unmasked: n}, nil
+	case "fake":
+		if ok {
+			return nil, fmt.Errorf("%s: %w", method, ErrMethod)
+		}
+		return &Fake{Key: key}, nil
+	case "hmac":
+		if ok && n <= 0 {
+			return nil, fmt.Errorf("%s: number of digits must be positive: %w", method, ErrMethod)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("%s: %w", method, ErrKey)
+		}
+		return &HMAC{Key: key, Size: n}, nil
+	}
 
-	return s[:unmasked] + strings.Repeat(string(m.Char), masked) + s[unmasked+masked:]
+	return nil, fmt.Errorf("%s: %w", method, ErrMethod)
 }
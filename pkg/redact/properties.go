@@ -0,0 +1,161 @@
+package redact
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// RedactProperties removes secrets from the values of a Java properties
+// file:
+//
+//	# comment
+//	spring.datasource.username = admin
+//	spring.datasource.password: secret
+//
+// Values are redacted if the rules detect a secret or if the key matches
+// a pattern set by WithKeyPaths: the key is split into a key path at
+// each dot, for example "$.spring.datasource.password" or "$..password".
+//
+// Escapes, including \uXXXX, and lines continued with a backslash are
+// decoded before secrets are detected. Comments are redacted as text.
+func (o *Opt) RedactProperties(s string) (string, error) {
+	s, _, err := o.RedactPropertiesWithFindings(s)
+	return s, err
+}
+
+// RedactPropertiesWithFindings removes secrets from a Java properties
+// file, returning the redacted file and a description of each secret.
+func (o *Opt) RedactPropertiesWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	return o.redactFields(s, parseProperties(s))
+}
+
+var propertiesEscapes = map[byte]string{
+	't': "\t", 'n': "\n", 'r': "\r", 'f': "\f",
+}
+
+func parseProperties(s string) []field {
+	fields := make([]field, 0)
+
+	for off := 0; off < len(s); {
+		end := lineEnd(s, off)
+		i := skipPropertiesSpace(s, off)
+
+		if i >= end || s[i] == '#' || s[i] == '!' {
+			fields = append(fields, literal(s, min(i, end), end, nil))
+			off = end + 1
+			continue
+		}
+
+		// The key ends at the first unescaped separator.
+		var key decoder
+		k := i
+		for k < len(s) && s[k] != '\n' && s[k] != '\r' && strings.IndexByte("=: \t\f", s[k]) < 0 {
+			if s[k] == '\\' {
+				k = propertiesEscape(s, k, &key)
+				continue
+			}
+			key.write(k, s[k:k+1])
+			k++
+		}
+
+		k = skipPropertiesSpace(s, k)
+		if k < len(s) && (s[k] == '=' || s[k] == ':') {
+			k = skipPropertiesSpace(s, k+1)
+		}
+
+		var value decoder
+		j := k
+		for k < len(s) && s[k] != '\n' && !strings.HasPrefix(s[k:], "\r\n") {
+			if s[k] == '\\' {
+				k = propertiesEscape(s, k, &value)
+				continue
+			}
+			value.write(k, s[k:k+1])
+			k++
+		}
+
+		path := make([]pathElem, 0)
+		for _, v := range strings.Split(key.b.String(), ".") {
+			path = append(path, pathElem{name: v})
+		}
+
+		fields = append(fields, value.field(path, j, k, k, propertiesQuote))
+		off = lineEnd(s, k) + 1
+	}
+
+	return fields
+}
+
+// propertiesEscape decodes the escape at offset i, returning the offset
+// following the escape. A backslash at the end of a line continues the
+// value on the next line, ignoring leading whitespace.
+func propertiesEscape(s string, i int, d *decoder) int {
+	if i+1 >= len(s) {
+		return i + 1
+	}
+
+	switch c := s[i+1]; {
+	case c == '\n':
+		return skipPropertiesSpace(s, i+2)
+	case c == '\r':
+		if i+2 < len(s) && s[i+2] == '\n' {
+			return skipPropertiesSpace(s, i+3)
+		}
+		return skipPropertiesSpace(s, i+2)
+	case c == 'u' && i+6 <= len(s):
+		n, err := strconv.ParseUint(s[i+2:i+6], 16, 16)
+		if err != nil {
+			break
+		}
+
+		r := rune(n)
+		end := i + 6
+
+		if utf16.IsSurrogate(r) {
+			r = utf8.RuneError
+			if end+6 <= len(s) && s[end] == '\\' && s[end+1] == 'u' {
+				if n2, err := strconv.ParseUint(s[end+2:end+6], 16, 16); err == nil {
+					if v := utf16.DecodeRune(rune(n), rune(n2)); v != utf8.RuneError {
+						r = v
+						end += 6
+					}
+				}
+			}
+		}
+
+		d.write(i, string(r))
+		return end
+	default:
+		if v, ok := propertiesEscapes[c]; ok {
+			d.write(i, v)
+			return i + 2
+		}
+	}
+
+	d.write(i, s[i+1:i+2])
+	return i + 2
+}
+
+func skipPropertiesSpace(s string, off int) int {
+	for off < len(s) && (s[off] == ' ' || s[off] == '\t' || s[off] == '\f') {
+		off++
+	}
+	return off
+}
+
+// propertiesQuote encodes v as a properties value.
+func propertiesQuote(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\f", `\f`)
+	v = r.Replace(v)
+
+	if strings.HasPrefix(v, " ") {
+		v = `\` + v
+	}
+	return v
+}
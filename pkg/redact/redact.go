@@ -4,12 +4,13 @@ package redact
 import (
 	"cmp"
 	_ "embed"
+	"fmt"
 	"go/token"
 	"regexp"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/spf13/viper"
 	"github.com/zricethezav/gitleaks/v8/config"
 	"github.com/zricethezav/gitleaks/v8/detect"
 	"github.com/zricethezav/gitleaks/v8/report"
@@ -18,11 +19,23 @@ import (
 
 const ReplacementText = "**REDACTED**"
 
+// Opt is the configuration for redacting secrets. An Opt is safe for
+// concurrent use by multiple goroutines.
 type Opt struct {
-	rules     string
-	overwrite overwrite.Replacer
-	d         *detect.Detector
-	err       error
+	rules          string
+	overwrite      overwrite.Replacer
+	byRule         map[string]overwrite.Replacer
+	parse          func(string) (overwrite.Replacer, error)
+	patterns       []string
+	paths          []*keyPath
+	fixed          bool
+	window         int
+	overlap        int
+	ignore         map[string]bool
+	fingerprintKey []byte
+	file           string
+	d              *detect.Detector
+	err            error
 }
 
 type Option func(*Opt)
@@ -38,6 +51,38 @@ func WithOverwrite(overwrite overwrite.Replacer) Option {
 	}
 }
 
+// WithRuleOverwrite sets the method for overwriting secrets detected
+// by the rule ID, taking precedence over the [redact] section of the
+// rules and over WithOverwrite.
+func WithRuleOverwrite(ruleID string, overwrite overwrite.Replacer) Option {
+	return func(o *Opt) {
+		o.byRule[ruleID] = overwrite
+	}
+}
+
+// WithParser sets the function converting the methods in the [redact]
+// section of the rules to a Replacer. The default parser is
+// overwrite.Parse using ReplacementText and no key.
+//
+// The [redact] section selects the method for a rule ID:
+//
+//	[[redact.rules]]
+//	id = "crypt-password-hash"
+//	method = "mask:10"
+func WithParser(fn func(method string) (overwrite.Replacer, error)) Option {
+	return func(o *Opt) {
+		o.parse = fn
+	}
+}
+
+// WithFixedLength truncates or pads each replacement with '*' to the
+// byte length of the secret, preserving the length of the input.
+func WithFixedLength() Option {
+	return func(o *Opt) {
+		o.fixed = true
+	}
+}
+
 // WithRules adds gitleaks rules to the configuration.
 func WithRules(s string) Option {
 	return func(o *Opt) {
@@ -52,21 +97,84 @@ func New(opt ...Option) *Opt {
 	o := &Opt{
 		rules:     config.DefaultConfig,
 		overwrite: &overwrite.Redact{Text: ReplacementText},
+		byRule:    make(map[string]overwrite.Replacer),
+		ignore:    make(map[string]bool),
+		parse:     parse,
+		window:    DefaultWindowSize,
+		overlap:   DefaultOverlapSize,
 	}
 
 	for _, fn := range opt {
 		fn(o)
 	}
 
-	d, err := newDetectorFromTOML(o.rules)
+	if o.fingerprintKey == nil {
+		o.fingerprintKey = randomKey()
+	}
+
+	if o.overlap >= o.window {
+		o.err = ErrInvalidWindow
+		return o
+	}
+
+	d, cfg, err := newDetectorFromTOML(o.rules)
 	if err != nil {
 		o.err = err
+		return o
 	}
 	o.d = d
 
+	for _, v := range append(o.patterns, cfg.paths...) {
+		k, err := parseKeyPath(v)
+		if err != nil {
+			o.err = err
+			return o
+		}
+		o.paths = append(o.paths, k)
+	}
+
+	methods := cfg.methods
+
+	ids := make([]string, 0, len(methods))
+	for id := range methods {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		if _, ok := d.Config.Rules[id]; !ok && id != KeyPathRuleID {
+			o.err = fmt.Errorf("%s: %w", id, ErrUnknownRule)
+			return o
+		}
+
+		if _, ok := o.byRule[id]; ok {
+			continue
+		}
+
+		r, err := o.parse(methods[id])
+		if err != nil {
+			o.err = fmt.Errorf("%s: %w", id, err)
+			return o
+		}
+		o.byRule[id] = r
+	}
+
 	return o
 }
 
+func parse(method string) (overwrite.Replacer, error) {
+	return overwrite.Parse(method, ReplacementText, nil)
+}
+
+// replacer returns the method for overwriting secrets detected by the
+// rule ID.
+func (o *Opt) replacer(ruleID string) overwrite.Replacer {
+	if r, ok := o.byRule[ruleID]; ok {
+		return r
+	}
+	return o.overwrite
+}
+
 func (o *Opt) Err() error {
 	return o.err
 }
@@ -77,21 +185,50 @@ func (o *Opt) Redact(s string) (string, error) {
 		return "", o.err
 	}
 
-	findings := o.d.DetectString(s)
+	return o.replace(s, o.detectString(s), 0)
+}
+
+// RedactWithFindings removes secrets detected in the provided string,
+// returning the redacted string and a description of each secret.
+func (o *Opt) RedactWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	v := o.detectString(s)
 
+	out, err := o.replace(s, v, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	c := newCursor()
+
+	return out, o.findingsAt(s, 0, &c, v), nil
+}
+
+// detectString returns the secrets found in s which are redacted.
+func (o *Opt) detectString(s string) []finding {
+	return o.allow(s, 1, o.detect(s), func(f finding) (int, int) {
+		return f.start, f.end
+	})
+}
+
+// finding is a secret located at a byte offset in the input.
+type finding struct {
+	report.Finding
+
+	start int
+	end   int
+}
+
+// detect returns the secrets found in s ordered by offset. Overlapping
+// findings are merged.
+func (o *Opt) detect(s string) []finding {
 	fset := token.NewFileSet()
 	f := fset.AddFile("", -1, len(s))
 	f.SetLinesForContent([]byte(s))
 
-	// Reverse sort the findings (last line/col first): replacing
-	// a secret will not affect the offset of the next finding.
-	slices.SortFunc(findings, func(a, b report.Finding) int {
-		if n := cmp.Compare(b.StartLine, a.StartLine); n != 0 {
-			return n
-		}
-		return cmp.Compare(b.StartColumn, a.StartColumn)
-	})
-
 	// * token package
 	//
 	// 	* line: 1-based
@@ -127,35 +264,183 @@ func (o *Opt) Redact(s string) (string, error) {
 	// ^ TOKEN:1,offset=1/0 GITLEAKS:0:1
 	//        ^ GITLEAKS:1:2
 	//        ^ TOKEN:2,offset=7/6
-	for _, finding := range findings {
+	findings := make([]finding, 0)
+
+	for _, v := range o.d.DetectString(s) {
+		if v.Secret == "" {
+			continue
+		}
+
 		nl := 1 // gitleaks column offset is 1-based.
-		if finding.StartLine > 0 {
+		if v.StartLine > 0 {
 			nl++ // Newline included in column count at start of line.
 		}
-		pos := f.LineStart(finding.StartLine + 1)
+		pos := f.LineStart(v.StartLine + 1)
 		// Convert 1-based column offset to 0-based string offset accounting for newline.
-		off := f.Offset(pos) + (finding.StartColumn - nl)
-		off += len(finding.Match) - len(finding.Secret)
-		s = s[:off] + o.overwrite.Replace(finding.Secret) + s[off+len(finding.Secret):]
+		off := f.Offset(pos) + (v.StartColumn - nl)
+
+		// The secret is usually the suffix of the match but may be
+		// any capture group.
+		if n := strings.LastIndex(v.Match, v.Secret); n >= 0 {
+			off += n
+		} else {
+			off += len(v.Match) - len(v.Secret)
+		}
+
+		if off < 0 || off+len(v.Secret) > len(s) || s[off:off+len(v.Secret)] != v.Secret {
+			continue
+		}
+
+		findings = append(findings, finding{
+			Finding: v,
+			start:   off,
+			end:     off + len(v.Secret),
+		})
 	}
 
-	return s, nil
+	return ordered(s, findings)
+}
+
+// detectField returns the secrets found in the value of a structured
+// document. The value is also detected in a line assigning it to its key:
+// rules requiring a key, such as generic-api-key, only match the value
+// with the key. Offsets are relative to the value.
+func (o *Opt) detectField(path []pathElem, value string) []finding {
+	findings := o.detect(value)
+
+	key := fieldKey(path)
+	if key == "" {
+		return findings
+	}
+
+	prefix := key + " = "
+	for _, v := range o.detect(prefix + value) {
+		if v.start < len(prefix) {
+			continue
+		}
+		v.start -= len(prefix)
+		v.end -= len(prefix)
+		findings = append(findings, v)
+	}
+
+	return ordered(value, findings)
+}
+
+// fieldKey returns the name of the nearest key of a value: the elements
+// of an array are assigned to the key of the array.
+func fieldKey(path []pathElem) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if !path[i].index {
+			return path[i].name
+		}
+	}
+	return ""
+}
+
+// ordered sorts the findings of s by offset and merges overlapping
+// findings: the secret of a merged finding extends from the start of the
+// first finding to the furthest end and is reported for the rule of the
+// first finding.
+func ordered(s string, findings []finding) []finding {
+	// Sort the findings by offset, longest match first.
+	slices.SortFunc(findings, func(a, b finding) int {
+		if n := cmp.Compare(a.start, b.start); n != 0 {
+			return n
+		}
+		return cmp.Compare(b.end, a.end)
+	})
+
+	v := findings[:0]
+	for _, f := range findings {
+		n := len(v) - 1
+		if n < 0 || f.start >= v[n].end {
+			v = append(v, f)
+			continue
+		}
+
+		if f.end > v[n].end {
+			v[n].end = f.end
+			v[n].Secret = s[v[n].start:v[n].end]
+		}
+	}
+
+	return v
 }
 
-func newDetectorFromTOML(s string) (*detect.Detector, error) {
-	viper.SetConfigType("toml")
-	if err := viper.ReadConfig(strings.NewReader(s)); err != nil {
-		return nil, err
+// replace overwrites the findings in s. The findings must be ordered
+// by offset and must not overlap. n is the number of secrets preceding
+// s in the input.
+func (o *Opt) replace(s string, findings []finding, n int) (string, error) {
+	if len(findings) == 0 {
+		return s, nil
 	}
 
-	var vc config.ViperConfig
-	if err := viper.Unmarshal(&vc); err != nil {
-		return nil, err
+	var b strings.Builder
+
+	off := 0
+	for i, v := range findings {
+		r, err := o.overwriteFinding(v, n+i+1)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(s[off:v.start])
+		b.WriteString(r)
+		off = v.end
 	}
+	b.WriteString(s[off:])
+
+	return b.String(), nil
+}
+
+// overwriteFinding returns the replacement for the secret. index is the
+// 1-based position of the secret in the input.
+func (o *Opt) overwriteFinding(v finding, index int) (string, error) {
+	var s string
+
+	switch r := o.replacer(v.RuleID).(type) {
+	case overwrite.MatchReplacer:
+		var err error
+		s, err = r.ReplaceMatch(overwrite.Match{
+			Secret:      v.Secret,
+			RuleID:      v.RuleID,
+			Description: v.Description,
+			Index:       index,
+			Fingerprint: o.fingerprint(v.Secret),
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", v.RuleID, err)
+		}
+	default:
+		s = r.Replace(v.Secret)
+	}
+
+	if o.fixed {
+		s = fit(s, len(v.Secret))
+	}
+
+	return s, nil
+}
+
+// fit truncates or pads s with '*' to n bytes. s is truncated at a rune
+// boundary.
+func fit(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+
+	return s + strings.Repeat("*", n-len(s))
+}
 
-	cfg, err := vc.Translate()
+// newDetectorFromTOML returns the detector for the rules and the
+// settings in the [redact] section.
+func newDetectorFromTOML(s string) (*detect.Detector, rulesConfig, error) {
+	cfg, err := readConfig(s, 0)
 	if err != nil {
-		return nil, err
+		return nil, rulesConfig{}, err
 	}
 
 	// Overwrite the default private key rule with a regexp with non-greedy matching.
@@ -165,6 +450,7 @@ func newDetectorFromTOML(s string) (*detect.Detector, error) {
 		Regex:       regexp.MustCompile(`(?i)-----BEGIN[ A-Z0-9_-]{0,100}PRIVATE KEY( BLOCK)?-----[\s\S-]*?KEY( BLOCK)?----`),
 		Keywords:    []string{"-----BEGIN"},
 	}
+	cfg.Keywords = append(cfg.Keywords, "-----begin")
 
-	return detect.NewDetector(cfg), nil
+	return detect.NewDetector(cfg.Config), cfg, nil
 }
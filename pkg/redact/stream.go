@@ -0,0 +1,146 @@
+package redact
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// DefaultWindowSize is the number of bytes read from a stream
+	// before secrets are detected.
+	DefaultWindowSize = 1024 * 1024
+
+	// DefaultOverlapSize is the number of bytes at the end of a
+	// window which are carried over to the next window. A secret
+	// longer than the overlap may not be detected if it crosses
+	// a window boundary.
+	DefaultOverlapSize = 64 * 1024
+)
+
+var ErrInvalidWindow = errors.New("overlap must be smaller than the window size")
+
+// WithWindowSize sets the number of bytes read from a stream before
+// secrets are detected.
+func WithWindowSize(n int) Option {
+	return func(o *Opt) {
+		if n > 0 {
+			o.window = n
+		}
+	}
+}
+
+// WithOverlapSize sets the number of bytes shared by consecutive windows
+// of a stream. Secrets spanning multiple lines, such as private keys,
+// are detected if they fit in the overlap.
+func WithOverlapSize(n int) Option {
+	return func(o *Opt) {
+		if n >= 0 {
+			o.overlap = n
+		}
+	}
+}
+
+// RedactStream removes secrets from r, writing the result to w.
+//
+// The input is processed in windows: memory use is bounded by the
+// window size rather than the size of the input.
+func (o *Opt) RedactStream(ctx context.Context, r io.Reader, w io.Writer) error {
+	_, err := o.RedactStreamWithFindings(ctx, r, w)
+	return err
+}
+
+// RedactStreamWithFindings removes secrets from r, writing the result to
+// w. A description of each secret is returned. Offsets in the findings
+// are relative to the start of the stream.
+func (o *Opt) RedactStreamWithFindings(ctx context.Context, r io.Reader, w io.Writer) ([]Finding, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	report := make([]Finding, 0)
+
+	base := 0
+	c := newCursor()
+
+	buf := make([]byte, 0, o.window+o.overlap)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		n, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		eof := false
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			eof = true
+		case err != nil:
+			return report, err
+		}
+
+		s := string(buf)
+		findings := o.allow(s, c.line, o.detect(s), func(f finding) (int, int) {
+			return f.start, f.end
+		})
+
+		cut := len(s)
+		if !eof {
+			cut = boundary(s, o.overlap, findings)
+		}
+
+		// Findings after the boundary are detected again in the
+		// next window.
+		for i, v := range findings {
+			if v.start >= cut {
+				findings = findings[:i]
+				break
+			}
+		}
+
+		out, err := o.replace(s[:cut], findings, len(report))
+		if err != nil {
+			return report, err
+		}
+
+		if _, err := io.WriteString(w, out); err != nil {
+			return report, err
+		}
+
+		report = append(report, o.findingsAt(s, base, &c, findings)...)
+
+		if eof {
+			return report, nil
+		}
+
+		c.advance(s, base, base+cut)
+		base += cut
+		buf = buf[:copy(buf, buf[cut:])]
+	}
+}
+
+// boundary returns the offset in s where the current window ends. The
+// boundary is placed at the start of a line where possible and is moved
+// forward past any secret crossing it.
+func boundary(s string, overlap int, findings []finding) int {
+	cut := len(s) - overlap
+	start := max(0, cut-overlap)
+
+	if n := strings.LastIndexByte(s[start:cut], '\n'); n >= 0 {
+		cut = start + n + 1
+	}
+
+	for _, v := range findings {
+		if v.start >= cut {
+			break
+		}
+		if v.end > cut {
+			cut = v.end
+		}
+	}
+
+	return cut
+}
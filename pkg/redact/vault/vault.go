@@ -0,0 +1,147 @@
+// Package vault replaces secrets with opaque tokens, storing the mapping
+// of tokens to secrets in a file encrypted with a user supplied key.
+//
+// A Vault implements the overwrite.Replacer interface.
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"go.iscode.ca/redact/internal/pkg/seal"
+)
+
+const tokenSize = 8 // bytes
+
+var tokenRe = regexp.MustCompile(`\*\*REDACTED:token:[0-9a-f]{16}\*\*`)
+
+// Vault maps tokens to secrets. A Vault is safe for concurrent use by
+// multiple goroutines.
+type Vault struct {
+	mu      sync.Mutex
+	secrets map[string]string // token: secret
+	tokens  map[string]string // secret: token
+}
+
+// New returns an empty vault.
+func New() *Vault {
+	return &Vault{
+		secrets: make(map[string]string),
+		tokens:  make(map[string]string),
+	}
+}
+
+// Load reads a vault from a file encrypted with key.
+func Load(name string, key []byte) (*Vault, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := seal.Open(key, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	v := New()
+
+	if err := json.Unmarshal(plaintext, &v.secrets); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	for token, secret := range v.secrets {
+		v.tokens[secret] = token
+	}
+
+	return v, nil
+}
+
+// Save writes the vault to a file encrypted with key. The file is
+// replaced atomically.
+func (v *Vault) Save(name string, key []byte) (err error) {
+	v.mu.Lock()
+	plaintext, err := json.Marshal(v.secrets)
+	v.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	b, err := seal.Seal(key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(f.Name()))
+		}
+	}()
+
+	if _, err := f.Write(b); err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	if err := f.Sync(); err != nil {
+		return errors.Join(err, f.Close())
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Replace returns the token for the secret. A new token is generated
+// the first time a secret is seen.
+func (v *Vault) Replace(s string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if token, ok := v.tokens[s]; ok {
+		return token
+	}
+
+	for {
+		b := make([]byte, tokenSize)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+
+		token := "**REDACTED:token:" + hex.EncodeToString(b) + "**"
+		if _, ok := v.secrets[token]; ok {
+			continue
+		}
+
+		v.secrets[token] = s
+		v.tokens[s] = token
+
+		return token
+	}
+}
+
+// Unredact replaces the tokens in s with the original secrets. Tokens
+// missing from the vault are not modified.
+func (v *Vault) Unredact(s string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return tokenRe.ReplaceAllStringFunc(s, func(token string) string {
+		if secret, ok := v.secrets[token]; ok {
+			return secret
+		}
+		return token
+	})
+}
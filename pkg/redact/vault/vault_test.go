@@ -0,0 +1,71 @@
+package vault_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.iscode.ca/redact/internal/pkg/seal"
+	"go.iscode.ca/redact/pkg/redact"
+	"go.iscode.ca/redact/pkg/redact/vault"
+)
+
+const rules = `[[rules]]
+id = "crypt-password-hash"
+description = "Detected a password hash"
+regex = '''\$(?:[a-zA-Z0-9]+)\$([^\s:]+)'''
+`
+
+func TestVault(t *testing.T) {
+	in := "x$9$abc123\ndef456\n$M$qwqe21034\n$1$abc123"
+
+	v := vault.New()
+
+	s, err := redact.New(redact.WithRules(rules), redact.WithOverwrite(v)).Redact(in)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+
+	if s == in {
+		t.Fatalf("redact failed: out=%s", s)
+	}
+
+	if v.Replace("abc123") != v.Replace("abc123") {
+		t.Fatalf("token is not stable")
+	}
+
+	name := filepath.Join(t.TempDir(), "vault")
+	key := []byte("test key")
+
+	if err := v.Save(name, key); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if _, err := seal.Open([]byte("wrong key"), b); !errors.Is(err, seal.ErrInvalid) {
+		t.Fatalf("open with invalid key: %v", err)
+	}
+
+	if _, err := vault.Load(name, []byte("wrong key")); !errors.Is(err, seal.ErrInvalid) {
+		t.Fatalf("load with invalid key: %v", err)
+	}
+
+	l, err := vault.Load(name, key)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if out := l.Unredact(s); out != in {
+		t.Fatalf("unredact failed: out=%s expected=%s", out, in)
+	}
+
+	unknown := "**REDACTED:token:0123456789abcdef**"
+	if out := l.Unredact(unknown); out != unknown {
+		t.Fatalf("unredact unknown token: out=%s", out)
+	}
+}
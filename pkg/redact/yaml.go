@@ -0,0 +1,641 @@
+package redact
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidYAML = errors.New("invalid YAML document")
+
+// RedactYAML removes secrets from the scalar values of a YAML document.
+// Values are redacted if the rules detect a secret or if their key path
+// matches a pattern set by WithKeyPaths. The values in the data section
+// of a Kubernetes Secret are base64 decoded before secrets are detected.
+//
+// Only the redacted values are modified: comments, anchors, aliases and
+// the quoting style of values are preserved. A redacted value is quoted
+// if the replacement is not valid in the original style.
+func (o *Opt) RedactYAML(s string) (string, error) {
+	s, _, err := o.RedactYAMLWithFindings(s)
+	return s, err
+}
+
+// RedactYAMLWithFindings removes secrets from a YAML document, returning
+// the redacted document and a description of each secret. Offsets in the
+// findings refer to the original document: if the secret does not appear
+// verbatim in the document, the finding spans the value containing the
+// secret.
+func (o *Opt) RedactYAMLWithFindings(s string) (string, []Finding, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+
+	docs, err := decodeYAML(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	p := &yamlParser{
+		o:       o,
+		s:       s,
+		lines:   lineOffsets(s),
+		flow:    make(map[*yaml.Node]bool),
+		changes: make(map[*yaml.Node]*yamlChange),
+	}
+
+	for _, n := range docs {
+		p.node(n, false, false)
+	}
+
+	if p.err != nil {
+		return "", nil, p.err
+	}
+
+	if len(p.changes) == 0 {
+		return s, make([]Finding, 0), nil
+	}
+
+	out, findings := p.edits()
+
+	if !equalYAML(out, docs) {
+		// The edits could not be located in the document: encode
+		// the modified document.
+		out, err = encodeYAML(docs)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	c := newCursor()
+
+	return out, o.findingsAt(s, 0, &c, findings), nil
+}
+
+// yamlChange is a redacted scalar value.
+type yamlChange struct {
+	old string
+	tag string
+
+	// whole is set if the value was redacted because of its key path.
+	whole bool
+
+	// base64 is set if the value was base64 decoded.
+	base64 bool
+
+	// findings are the secrets in the value. Offsets are relative to
+	// the decoded value.
+	findings []finding
+}
+
+// yamlParser walks a YAML document, redacting scalar values.
+type yamlParser struct {
+	o     *Opt
+	s     string
+	lines []int
+
+	path []pathElem
+	n    int
+
+	// err is the first error replacing a secret.
+	err error
+
+	// flow records whether a scalar is in a flow collection.
+	flow    map[*yaml.Node]bool
+	changes map[*yaml.Node]*yamlChange
+}
+
+func (p *yamlParser) node(n *yaml.Node, matched, flow bool) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			p.node(c, matched, flow)
+		}
+	case yaml.MappingNode:
+		flow = flow || n.Style&yaml.FlowStyle != 0
+		secret := len(p.path) == 0 && isKubernetesSecret(n)
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+
+			p.path = append(p.path, pathElem{name: k.Value})
+			m := matched || p.match()
+
+			if secret && k.Value == "data" && v.Kind == yaml.MappingNode {
+				p.secretData(v, m, flow)
+			} else {
+				p.node(v, m, flow)
+			}
+
+			p.path = p.path[:len(p.path)-1]
+		}
+	case yaml.SequenceNode:
+		flow = flow || n.Style&yaml.FlowStyle != 0
+
+		for i, c := range n.Content {
+			p.path = append(p.path, pathElem{name: strconv.Itoa(i), index: true})
+			p.node(c, matched || p.match(), flow)
+			p.path = p.path[:len(p.path)-1]
+		}
+	case yaml.AliasNode:
+		// The anchored value is redacted where it is defined.
+		if matched && n.Alias != nil {
+			p.node(n.Alias, true, flow)
+		}
+	case yaml.ScalarNode:
+		p.scalar(n, matched, flow, false)
+	}
+}
+
+func (p *yamlParser) match() bool {
+	_, ok := p.o.matchPath(p.path)
+	return ok
+}
+
+// secretData redacts the base64 encoded values of a Kubernetes Secret.
+func (p *yamlParser) secretData(n *yaml.Node, matched, flow bool) {
+	flow = flow || n.Style&yaml.FlowStyle != 0
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+
+		p.path = append(p.path, pathElem{name: k.Value})
+		if v.Kind == yaml.ScalarNode {
+			p.scalar(v, matched || p.match(), flow, true)
+		} else {
+			p.node(v, matched || p.match(), flow)
+		}
+		p.path = p.path[:len(p.path)-1]
+	}
+}
+
+func (p *yamlParser) scalar(n *yaml.Node, matched, flow, b64 bool) {
+	if f, ok := p.flow[n]; ok {
+		// The scalar is anchored and has already been visited: a
+		// value matched by key path through an alias replaces any
+		// previous redaction.
+		c, ok := p.changes[n]
+		if !matched || ok && c.whole {
+			return
+		}
+		if ok {
+			n.Value, n.Tag = c.old, c.tag
+			delete(p.changes, n)
+		}
+		flow = f
+	}
+	p.flow[n] = flow
+
+	if n.ShortTag() == "!!null" {
+		return
+	}
+
+	value := n.Value
+	if b64 {
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			b64 = false
+		} else {
+			value = string(b)
+		}
+	}
+
+	c := &yamlChange{old: n.Value, tag: n.Tag, whole: matched, base64: b64}
+
+	var v string
+
+	switch {
+	case matched:
+		pattern, _ := p.o.matchPath(p.path)
+
+		f := finding{}
+		f.RuleID = KeyPathRuleID
+		f.Description = "Matched key path " + pattern
+		f.Secret = value
+
+		var err error
+		v, err = p.o.overwriteFinding(f, p.n+1)
+		if err != nil {
+			p.err = cmp.Or(p.err, err)
+			return
+		}
+		c.findings = []finding{f}
+	case n.ShortTag() == "!!str":
+		c.findings = p.o.allow(p.s, 1, p.o.detectField(p.path, value), func(f finding) (int, int) {
+			return p.secretOffset(n, value, f.start, f.end)
+		})
+		if len(c.findings) == 0 {
+			return
+		}
+		var err error
+		v, err = p.o.replace(value, c.findings, p.n)
+		if err != nil {
+			p.err = cmp.Or(p.err, err)
+			return
+		}
+	default:
+		return
+	}
+
+	p.n += len(c.findings)
+
+	if b64 {
+		v = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+
+	n.Value = v
+	n.Tag = "!!str"
+	p.changes[n] = c
+}
+
+// isKubernetesSecret reports whether the mapping is a Kubernetes Secret.
+func isKubernetesSecret(n *yaml.Node) bool {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "kind" && n.Content[i+1].Value == "Secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// edits applies the redacted values to the original document, returning
+// the redacted document and the secrets ordered by offset.
+func (p *yamlParser) edits() (string, []finding) {
+	nodes := make([]*yaml.Node, 0, len(p.changes))
+	for n := range p.changes {
+		nodes = append(nodes, n)
+	}
+
+	slices.SortFunc(nodes, func(a, b *yaml.Node) int {
+		if n := cmp.Compare(a.Line, b.Line); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.Column, b.Column)
+	})
+
+	edits := make([]edit, 0, len(nodes))
+	findings := make([]finding, 0, len(nodes))
+
+	for _, n := range nodes {
+		c := p.changes[n]
+
+		e, ok := p.extent(n, c.old)
+		if ok {
+			e.text = p.quote(n, e)
+			edits = append(edits, e)
+		} else {
+			e.end = e.start
+		}
+
+		raw := p.s[e.start:e.end]
+		for _, f := range c.findings {
+			start, end := e.start, e.end
+			if i := strings.Index(raw, f.Secret); i >= 0 && !c.base64 && f.Secret != "" {
+				start, end = e.start+i, e.start+i+len(f.Secret)
+			}
+			f.start, f.end = start, end
+			findings = append(findings, f)
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b finding) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	return splice(p.s, edits), findings
+}
+
+// extent locates the scalar in the document. old is the value of the
+// scalar before redaction. The properties of the node, such as an anchor
+// or tag, are not included.
+func (p *yamlParser) extent(n *yaml.Node, old string) (edit, bool) {
+	start := p.offset(n.Line, n.Column)
+	s := p.s
+
+	for start < len(s) && (s[start] == '&' || s[start] == '!') {
+		for start < len(s) && !isSpace(s[start]) {
+			start++
+		}
+		for start < len(s) && (s[start] == ' ' || s[start] == '\t') {
+			start++
+		}
+	}
+
+	e := edit{start: start, end: start}
+	if start >= len(s) {
+		return e, false
+	}
+
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		i := start + 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(s) {
+			return e, false
+		}
+		e.end = i + 1
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		i := start + 1
+		for ; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		if i >= len(s) {
+			return e, false
+		}
+		e.end = i + 1
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		i := strings.IndexByte(s[start:], '\n')
+		if i < 0 {
+			return e, false
+		}
+
+		// The block ends at the last line indented more than the
+		// line containing the header.
+		indent := -1
+		end := start + i
+		for off := end + 1; off < len(s); {
+			line, _, _ := strings.Cut(s[off:], "\n")
+			if strings.TrimSpace(line) != "" {
+				n := len(line) - len(strings.TrimLeft(line, " "))
+				if indent < 0 {
+					indent = n
+				}
+				if n < indent {
+					break
+				}
+				end = off + len(line)
+			}
+			off += len(line) + 1
+		}
+
+		if indent <= p.indent(start) {
+			return e, false
+		}
+		e.end = end
+	default:
+		i := start
+		for ; i < len(s) && s[i] != '\n' && s[i] != '\r'; i++ {
+			if s[i] == '#' && isSpace(s[i-1]) {
+				break
+			}
+			if p.flow[n] && strings.IndexByte(",]}", s[i]) >= 0 {
+				break
+			}
+		}
+		e.end = start + len(strings.TrimRight(s[start:i], " \t"))
+		if s[start:e.end] != old {
+			end, ok := p.plainEnd(n, start, e.end, old)
+			if !ok {
+				return e, false
+			}
+			e.end = end
+		}
+	}
+
+	return e, true
+}
+
+// plainEnd returns the end of a plain scalar continued on the lines
+// following the line ending at end. The continuation lines are indented
+// more than the line containing the start of the scalar and are folded
+// into the value, separated by spaces.
+func (p *yamlParser) plainEnd(n *yaml.Node, start, end int, old string) (int, bool) {
+	if p.flow[n] {
+		return end, false
+	}
+
+	s := p.s
+	indent := p.indent(start)
+	parts := []string{s[start:end]}
+
+	for off := end; ; {
+		i := strings.IndexByte(s[off:], '\n')
+		if i < 0 {
+			break
+		}
+		off += i + 1
+
+		line, _, _ := strings.Cut(s[off:], "\n")
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") || strings.Contains(text, " #") ||
+			len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+			break
+		}
+
+		parts = append(parts, text)
+		end = off + len(strings.TrimRight(line, " \t\r"))
+	}
+
+	return end, len(parts) > 1 && strings.Join(parts, " ") == old
+}
+
+// quote returns the text replacing the scalar in the style of the
+// original value.
+func (p *yamlParser) quote(n *yaml.Node, e edit) string {
+	v := n.Value
+
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		return quoteJSON(v)
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		raw := p.s[e.start:e.end]
+		header, content, _ := strings.Cut(raw, "\n")
+		indent := len(content) - len(strings.TrimLeft(content, " "))
+
+		lines := strings.Split(strings.TrimRight(v, "\n"), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = strings.Repeat(" ", indent) + line
+			}
+		}
+		return header + "\n" + strings.Join(lines, "\n")
+	case n.Style&yaml.SingleQuotedStyle != 0:
+	default:
+		if isPlain(v, p.flow[n]) {
+			return v
+		}
+	}
+
+	if strings.ContainsAny(v, "\n\r\t") || !utf8.ValidString(v) {
+		return quoteJSON(v)
+	}
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// isPlain reports whether s can be written as a plain scalar resolving
+// to the same string.
+func isPlain(s string, flow bool) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\n\r\t") {
+		return false
+	}
+
+	if strings.IndexByte("!&*-?:,[]{}#|>@`\"'%", s[0]) >= 0 {
+		return false
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+
+	if flow && strings.ContainsAny(s, ",[]{}") {
+		return false
+	}
+
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return false
+	}
+	x, ok := v.(string)
+	return ok && x == s
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// offset converts the 1-based line and column of a node to a byte offset.
+// Columns are counted in characters.
+func (p *yamlParser) offset(line, col int) int {
+	if line < 1 || line > len(p.lines) {
+		return len(p.s)
+	}
+
+	off := p.lines[line-1]
+	for ; col > 1 && off < len(p.s); col-- {
+		_, n := utf8.DecodeRuneInString(p.s[off:])
+		off += n
+	}
+	return off
+}
+
+// indent returns the indentation of the line containing the offset.
+func (p *yamlParser) indent(off int) int {
+	start := strings.LastIndexByte(p.s[:off], '\n') + 1
+	line := p.s[start:off]
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// secretOffset returns the offsets in the document of the secret
+// located from start to end of the scalar value. If the secret does not
+// appear verbatim in the scalar, the offset of the scalar is returned.
+func (p *yamlParser) secretOffset(n *yaml.Node, value string, start, end int) (int, int) {
+	e, ok := p.extent(n, n.Value)
+	if !ok || value != n.Value {
+		return e.start, e.start
+	}
+
+	if i := strings.Index(p.s[e.start:e.end], value[start:end]); i >= 0 {
+		return e.start + i, e.start + i + end - start
+	}
+
+	return e.start, e.start
+}
+
+func lineOffsets(s string) []int {
+	v := []int{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			v = append(v, i+1)
+		}
+	}
+	return v
+}
+
+func decodeYAML(s string) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(s))
+
+	docs := make([]*yaml.Node, 0, 1)
+	for {
+		var n yaml.Node
+		err := dec.Decode(&n)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidYAML, err)
+		}
+		docs = append(docs, &n)
+	}
+}
+
+// equalYAML reports whether the document s contains the same values as
+// the nodes.
+func equalYAML(s string, docs []*yaml.Node) bool {
+	v, err := decodeYAML(s)
+	if err != nil || len(v) != len(docs) {
+		return false
+	}
+
+	for i := range v {
+		if !equalNode(v[i], docs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalNode(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Anchor != b.Anchor || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	if a.Kind == yaml.ScalarNode && (a.Value != b.Value || a.ShortTag() != b.ShortTag()) {
+		return false
+	}
+
+	for i := range a.Content {
+		if !equalNode(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeYAML(docs []*yaml.Node) (string, error) {
+	var b strings.Builder
+
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+
+	for _, n := range docs {
+		clearMergeTags(n)
+		if err := enc.Encode(n); err != nil {
+			return "", err
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// clearMergeTags removes the tag from merge keys: the encoder writes the
+// tag explicitly.
+func clearMergeTags(n *yaml.Node) {
+	if n.Tag == "!!merge" {
+		n.Tag = ""
+	}
+	for _, c := range n.Content {
+		clearMergeTags(c)
+	}
+}